@@ -0,0 +1,270 @@
+// Command mockoidc is a minimal OpenID Connect issuer for trying single sign-on
+// locally. It signs in whoever fills its form, so never expose it.
+//
+//	go run ./cmd/mockoidc -addr :9998
+//
+// Then start the backend with OIDC_ALLOW_INSECURE_ISSUERS=true and configure the
+// account with issuer http://localhost:9998, client ID "chatwoot" and client
+// secret "secret".
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-key"
+
+// authorization is an issued code waiting to be exchanged
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	email         string
+	emailVerified bool
+	name          string
+	groups        []string
+	expiresAt     time.Time
+}
+
+type issuer struct {
+	url          string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+var loginForm = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><title>Mock OIDC</title></head>
+<body style="font-family: sans-serif; max-width: 420px; margin: 60px auto">
+<h2>Mock OIDC sign-in</h2>
+<form method="post">
+  {{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">{{end}}
+  <p><label>Email<br><input name="email" value="{{.Email}}" size="40" required></label></p>
+  <p><label>Name<br><input name="name" size="40"></label></p>
+  <p><label>Groups (comma separated)<br><input name="groups" size="40"></label></p>
+  <p><label><input type="checkbox" name="email_verified" checked> Email verified</label></p>
+  <button type="submit">Sign in</button>
+</form>
+</body></html>`))
+
+func main() {
+	addr := flag.String("addr", ":9998", "listen address")
+	issuerURL := flag.String("issuer", "http://localhost:9998", "issuer URL, as seen by the backend and the browser")
+	clientID := flag.String("client-id", "chatwoot", "accepted client ID")
+	clientSecret := flag.String("client-secret", "secret", "accepted client secret")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal("Failed to generate signing key:", err)
+	}
+
+	iss := &issuer{
+		url:          strings.TrimSuffix(*issuerURL, "/"),
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		key:          key,
+		codes:        make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", iss.discovery)
+	mux.HandleFunc("/jwks", iss.jwks)
+	mux.HandleFunc("/authorize", iss.authorize)
+	mux.HandleFunc("/token", iss.token)
+
+	log.Printf("Mock OIDC issuer %s listening on %s (client %s)", iss.url, *addr, iss.clientID)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (iss *issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                iss.url,
+		"authorization_endpoint":                iss.url + "/authorize",
+		"token_endpoint":                        iss.url + "/token",
+		"jwks_uri":                              iss.url + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (iss *issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := iss.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize shows the sign-in form (GET) and redirects back with a code (POST)
+func (iss *issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+
+	if params.Get("client_id") != iss.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	if params.Get("response_type") != "code" || params.Get("redirect_uri") == "" {
+		http.Error(w, "only response_type=code with a redirect_uri is supported", http.StatusBadRequest)
+		return
+	}
+	if params.Get("code_challenge") == "" || params.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		hidden := url.Values{}
+		for _, name := range []string{"client_id", "redirect_uri", "response_type", "state", "nonce", "code_challenge", "code_challenge_method"} {
+			hidden.Set(name, params.Get(name))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginForm.Execute(w, map[string]interface{}{"Params": hidden, "Email": params.Get("login_hint")})
+		return
+	}
+
+	var groups []string
+	for _, group := range strings.Split(params.Get("groups"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	code := randomString()
+	iss.mu.Lock()
+	iss.codes[code] = authorization{
+		clientID:      params.Get("client_id"),
+		redirectURI:   params.Get("redirect_uri"),
+		codeChallenge: params.Get("code_challenge"),
+		nonce:         params.Get("nonce"),
+		email:         params.Get("email"),
+		emailVerified: params.Get("email_verified") != "",
+		name:          params.Get("name"),
+		groups:        groups,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	iss.mu.Unlock()
+
+	redirect, err := url.Parse(params.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("state", params.Get("state"))
+	redirect.RawQuery = query.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token, checking the client and the PKCE verifier
+func (iss *issuer) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != iss.clientID || clientSecret != iss.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	iss.mu.Lock()
+	auth, found := iss.codes[code]
+	delete(iss.codes, code)
+	iss.mu.Unlock()
+
+	if !found || time.Now().After(auth.expiresAt) || auth.clientID != clientID ||
+		auth.redirectURI != r.PostForm.Get("redirect_uri") || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            iss.url,
+		"sub":            auth.email,
+		"aud":            clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          auth.nonce,
+		"email":          auth.email,
+		"email_verified": auth.emailVerified,
+		"name":           auth.name,
+		"groups":         auth.groups,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(iss.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
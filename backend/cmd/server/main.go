@@ -5,13 +5,20 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/nakamura/chatwoot-go/internal/config"
 	"github.com/nakamura/chatwoot-go/internal/database"
+	"github.com/nakamura/chatwoot-go/internal/mailer"
 	"github.com/nakamura/chatwoot-go/internal/middleware"
+	"github.com/nakamura/chatwoot-go/internal/notifications"
+	"github.com/nakamura/chatwoot-go/internal/presence"
 	"github.com/nakamura/chatwoot-go/internal/routes"
+	"github.com/nakamura/chatwoot-go/internal/schedule"
+	"github.com/nakamura/chatwoot-go/internal/sla"
 	"github.com/nakamura/chatwoot-go/internal/storage"
 	"github.com/nakamura/chatwoot-go/internal/websocket"
 )
@@ -41,8 +48,30 @@ func main() {
 
 	// Initialize WebSocket hub
 	wsHub := websocket.NewHub()
+	wsHub.UseRedis(redisClient, cfg.NodeID)
 	go wsHub.Run()
 
+	// Initialize SLA monitor
+	slaService := sla.NewService(db, wsHub)
+	slaService.CalendarFor = func(inboxID uuid.UUID) sla.Calendar {
+		// Count business time using the inbox working hours when enabled
+		sched, err := schedule.Load(db, inboxID)
+		if err != nil || sched == nil {
+			return sla.AlwaysOpen{}
+		}
+		return sched
+	}
+
+	// Initialize notifications; the SLA monitor notifies agents of breaches
+	notifier := notifications.New(db, wsHub, mailer.New(cfg), cfg)
+	slaService.OnBreach = notifier.SLABreached
+	go slaService.Run(time.Minute)
+	go notifier.RunDigests(15 * time.Minute)
+
+	// Initialize presence tracking
+	presenceTracker := presence.NewTracker(db, wsHub, presence.NewStore(redisClient))
+	go presenceTracker.Run(30 * time.Second)
+
 	// Initialize Minio
 	minioService, err := storage.NewMinioService(cfg)
 	if err != nil {
@@ -90,7 +119,7 @@ func main() {
 	})
 
 	// Setup API routes (ANTES das rotas estáticas)
-	routes.SetupRoutes(router, db, redisClient, wsHub, minioService, cfg)
+	routes.SetupRoutes(router, db, redisClient, wsHub, slaService, presenceTracker, notifier, minioService, cfg)
 
 	// Serve static frontend files (SPA) - Padrão Evolution-Go
 	distPath := "./dist"
@@ -0,0 +1,113 @@
+package channels
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nakamura/chatwoot-go/internal/config"
+	"github.com/nakamura/chatwoot-go/internal/models"
+)
+
+// ErrNoProvider is returned for channel types without outgoing delivery (e.g. api, web widget)
+var ErrNoProvider = errors.New("channel has no outgoing provider")
+
+// ErrPrivateMessage is returned for private notes, which never leave the account
+var ErrPrivateMessage = errors.New("private notes are not delivered to channels")
+
+// Provider delivers outgoing messages to an external channel
+type Provider interface {
+	// SendText sends a text message to a contact and returns the external message ID
+	SendText(ctx context.Context, inbox *models.Inbox, contact *models.Contact, content string) (string, error)
+}
+
+// TypingNotifier is implemented by providers that can show the contact that an agent
+// is typing
+type TypingNotifier interface {
+	SendTyping(ctx context.Context, inbox *models.Inbox, contact *models.Contact, typing bool) error
+}
+
+// MessageEditor is implemented by providers that can change the text of a message
+// the contact already received
+type MessageEditor interface {
+	EditText(ctx context.Context, inbox *models.Inbox, contact *models.Contact, sourceID, content string) error
+}
+
+// MessageRevoker is implemented by providers that can delete a message for the contact
+type MessageRevoker interface {
+	Revoke(ctx context.Context, inbox *models.Inbox, contact *models.Contact, sourceID string) error
+}
+
+// Registry maps inbox channel types to their providers
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry creates a registry with the providers enabled by the configuration
+func NewRegistry(cfg *config.Config) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	if cfg.EvolutionAPIURL != "" {
+		r.Register("whatsapp", NewEvolutionProvider(cfg.EvolutionAPIURL, cfg.EvolutionAPIKey))
+	}
+	return r
+}
+
+// Register sets the provider of a channel type
+func (r *Registry) Register(channelType string, provider Provider) {
+	r.providers[channelType] = provider
+}
+
+// For returns the provider of a channel type, or nil
+func (r *Registry) For(channelType string) Provider {
+	if r == nil {
+		return nil
+	}
+	return r.providers[channelType]
+}
+
+// SendText delivers a text message through the inbox channel
+func (r *Registry) SendText(ctx context.Context, inbox *models.Inbox, contact *models.Contact, content string) (string, error) {
+	provider := r.For(inbox.ChannelType)
+	if provider == nil {
+		return "", ErrNoProvider
+	}
+	return provider.SendText(ctx, inbox, contact, content)
+}
+
+// Deliver sends an agent's message through the inbox channel and returns the external
+// message ID. Private notes are refused with ErrPrivateMessage.
+func (r *Registry) Deliver(ctx context.Context, inbox *models.Inbox, contact *models.Contact, message *models.Message) (string, error) {
+	if message.Private {
+		return "", ErrPrivateMessage
+	}
+	return r.SendText(ctx, inbox, contact, message.Content)
+}
+
+// SendTyping shows or hides the typing indicator on the inbox channel. Channels that
+// cannot show it return ErrNoProvider.
+func (r *Registry) SendTyping(ctx context.Context, inbox *models.Inbox, contact *models.Contact, typing bool) error {
+	notifier, ok := r.For(inbox.ChannelType).(TypingNotifier)
+	if !ok {
+		return ErrNoProvider
+	}
+	return notifier.SendTyping(ctx, inbox, contact, typing)
+}
+
+// EditText changes a message delivered through the inbox channel. Channels that cannot
+// edit messages return ErrNoProvider.
+func (r *Registry) EditText(ctx context.Context, inbox *models.Inbox, contact *models.Contact, sourceID, content string) error {
+	editor, ok := r.For(inbox.ChannelType).(MessageEditor)
+	if !ok {
+		return ErrNoProvider
+	}
+	return editor.EditText(ctx, inbox, contact, sourceID, content)
+}
+
+// Revoke deletes a message delivered through the inbox channel. Channels that cannot
+// delete messages return ErrNoProvider.
+func (r *Registry) Revoke(ctx context.Context, inbox *models.Inbox, contact *models.Contact, sourceID string) error {
+	revoker, ok := r.For(inbox.ChannelType).(MessageRevoker)
+	if !ok {
+		return ErrNoProvider
+	}
+	return revoker.Revoke(ctx, inbox, contact, sourceID)
+}
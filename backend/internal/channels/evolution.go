@@ -0,0 +1,126 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nakamura/chatwoot-go/internal/models"
+)
+
+// EvolutionProvider sends WhatsApp messages through the Evolution API.
+// The inbox name is the Evolution instance name, as created by the incoming webhook.
+type EvolutionProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewEvolutionProvider creates a new Evolution API provider
+func NewEvolutionProvider(baseURL, apiKey string) *EvolutionProvider {
+	return &EvolutionProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SendText implements Provider
+func (p *EvolutionProvider) SendText(ctx context.Context, inbox *models.Inbox, contact *models.Contact, content string) (string, error) {
+	var response struct {
+		Key struct {
+			ID string `json:"id"`
+		} `json:"key"`
+	}
+
+	err := p.do(ctx, http.MethodPost, "/message/sendText/"+url.PathEscape(inbox.Name), map[string]interface{}{
+		"number": contact.PhoneNumber,
+		"text":   content,
+	}, &response)
+	if err != nil {
+		return "", err
+	}
+	return response.Key.ID, nil
+}
+
+// SendTyping implements TypingNotifier with the WhatsApp "composing" presence
+func (p *EvolutionProvider) SendTyping(ctx context.Context, inbox *models.Inbox, contact *models.Contact, typing bool) error {
+	presence := "paused"
+	if typing {
+		presence = "composing"
+	}
+	return p.do(ctx, http.MethodPost, "/chat/sendPresence/"+url.PathEscape(inbox.Name), map[string]interface{}{
+		"number":   contact.PhoneNumber,
+		"presence": presence,
+		"delay":    10000, // how long WhatsApp shows it, in milliseconds
+	}, nil)
+}
+
+// EditText implements MessageEditor
+func (p *EvolutionProvider) EditText(ctx context.Context, inbox *models.Inbox, contact *models.Contact, sourceID, content string) error {
+	return p.do(ctx, http.MethodPost, "/chat/updateMessage/"+url.PathEscape(inbox.Name), map[string]interface{}{
+		"number": contact.PhoneNumber,
+		"text":   content,
+		"key": map[string]interface{}{
+			"remoteJid": remoteJid(contact),
+			"fromMe":    true,
+			"id":        sourceID,
+		},
+	}, nil)
+}
+
+// Revoke implements MessageRevoker, deleting the message for everyone
+func (p *EvolutionProvider) Revoke(ctx context.Context, inbox *models.Inbox, contact *models.Contact, sourceID string) error {
+	return p.do(ctx, http.MethodDelete, "/chat/deleteMessageForEveryone/"+url.PathEscape(inbox.Name), map[string]interface{}{
+		"id":        sourceID,
+		"remoteJid": remoteJid(contact),
+		"fromMe":    true,
+	}, nil)
+}
+
+// remoteJid is the WhatsApp JID of a contact
+func remoteJid(contact *models.Contact) string {
+	if strings.Contains(contact.PhoneNumber, "@") {
+		return contact.PhoneNumber
+	}
+	return strings.TrimPrefix(contact.PhoneNumber, "+") + "@s.whatsapp.net"
+}
+
+// do performs an authenticated JSON request against the Evolution API
+func (p *EvolutionProvider) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apikey", p.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("evolution api %s %s: status %d: %s", method, path, resp.StatusCode, respBody)
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("evolution api %s %s: invalid response: %w", method, path, err)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -19,10 +23,41 @@ type Config struct {
 	// JWT
 	JWTSecret string
 
+	// Channels
+	EvolutionAPIURL string
+	EvolutionAPIKey string
+
+	// Messages
+	// MessageEditWindow is how long agents can edit a message after sending it; 0 for no limit
+	MessageEditWindow time.Duration
+
+	// Notifications
+	// DigestHour is the hour of the day (UTC) from which daily digests are sent
+	DigestHour int
+
+	// Mail
+	MailerDriver  string
+	MailerFrom    string
+	MailerFileDir string
+	SMTPHost      string
+	SMTPPort      string
+	SMTPUsername  string
+	SMTPPassword  string
+
+	// Single sign-on
+	// OIDCAllowInsecureIssuers lets identity providers use http and private
+	// addresses; only for local testing (e.g. cmd/mockoidc)
+	OIDCAllowInsecureIssuers bool
+
 	// Server
 	Port        string
 	FrontendURL string
-	GoEnv       string
+	// AllowedOrigins are origins besides FrontendURL (and the server itself) whose
+	// pages may open WebSockets
+	AllowedOrigins []string
+	GoEnv          string
+	// NodeID identifies this replica on the WebSocket bus; derived from the hostname when empty
+	NodeID string
 
 	// Features
 	EnableWebhooks bool
@@ -45,10 +80,34 @@ func New() *Config {
 		// JWT
 		JWTSecret: getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
 
+		// Channels
+		EvolutionAPIURL: getEnv("EVOLUTION_API_URL", ""),
+		EvolutionAPIKey: getEnv("EVOLUTION_API_KEY", ""),
+
+		// Messages
+		MessageEditWindow: getDuration("MESSAGE_EDIT_WINDOW", 15*time.Minute),
+
+		// Notifications
+		DigestHour: getInt("DIGEST_HOUR", 8),
+
+		// Mail
+		MailerDriver:  getEnv("MAILER_DRIVER", "log"),
+		MailerFrom:    getEnv("MAILER_FROM", "Chatwoot <no-reply@localhost>"),
+		MailerFileDir: getEnv("MAILER_FILE_DIR", "tmp/mails"),
+		SMTPHost:      getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:      getEnv("SMTP_PORT", "587"),
+		SMTPUsername:  getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+
+		// Single sign-on
+		OIDCAllowInsecureIssuers: getEnv("OIDC_ALLOW_INSECURE_ISSUERS", "false") == "true",
+
 		// Server
-		Port:        getEnv("PORT", "8080"),
-		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:5173"),
-		GoEnv:       getEnv("GO_ENV", "development"),
+		Port:           getEnv("PORT", "8080"),
+		FrontendURL:    getEnv("FRONTEND_URL", "http://localhost:5173"),
+		AllowedOrigins: getList("ALLOWED_ORIGINS"),
+		GoEnv:          getEnv("GO_ENV", "development"),
+		NodeID:         getEnv("NODE_ID", ""),
 
 		// Features
 		EnableWebhooks: getEnv("ENABLE_WEBHOOKS", "true") == "true",
@@ -62,3 +121,40 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getList reads a comma-separated list
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"log"
+	"strings"
 
 	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/security"
 	"gorm.io/gorm"
 )
 
@@ -16,26 +18,150 @@ func RunMigrations(db *gorm.DB) error {
 		return err
 	}
 
+	if err := hashLegacyAccessTokens(db); err != nil {
+		return err
+	}
+
+	// Inbox visibility started with no members; existing agents keep their access
+	backfillInboxMembers := !db.Migrator().HasTable(&models.InboxMember{})
+
 	// Auto-migrate all models
 	err := db.AutoMigrate(
 		&models.Account{},
 		&models.User{},
 		&models.AccountUser{},
+		&models.Invitation{},
+		&models.PasswordResetToken{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.OIDCProvider{},
+		&models.OIDCLoginRequest{},
+		&models.RecoveryCode{},
+		&models.UnlockToken{},
+		&models.LoginFailure{},
+		&models.AuditLog{},
 		&models.Inbox{},
+		&models.InboxMember{},
+		&models.WorkingHour{},
+		&models.InboxHoliday{},
 		&models.Contact{},
+		&models.SLAPolicy{},
 		&models.Conversation{},
+		&models.SLAEvent{},
 		&models.Message{},
+		&models.MessageRevision{},
 		&models.Attachment{},
 		&models.Team{},
 		&models.Label{},
 		&models.Webhook{},
 		&models.AccessToken{},
+		&models.Notification{},
+		&models.NotificationSetting{},
 	)
 
 	if err != nil {
 		return err
 	}
 
+	if err := migrateUserAccessTokens(db); err != nil {
+		return err
+	}
+
+	// SLA events used to be unique per conversation, metric and type, which kept reopened
+	// conversations from being tracked again; they are now unique per deadline
+	if err := db.Exec("DROP INDEX IF EXISTS idx_sla_event").Error; err != nil {
+		return err
+	}
+
+	if backfillInboxMembers {
+		if err := addAccountUsersToInboxes(db); err != nil {
+			return err
+		}
+	}
+
 	log.Println("✅ Database migrations completed successfully")
 	return nil
 }
+
+// hashLegacyAccessTokens replaces the plaintext access_tokens.token column by its SHA-256.
+// Tokens created before scopes existed had full access and get every scope.
+func hashLegacyAccessTokens(db *gorm.DB) error {
+	if !db.Migrator().HasTable("access_tokens") || !db.Migrator().HasColumn("access_tokens", "token") {
+		return nil
+	}
+
+	log.Println("🔄 Hashing stored access tokens...")
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"ALTER TABLE access_tokens ADD COLUMN IF NOT EXISTS token_hash text",
+			"ALTER TABLE access_tokens ADD COLUMN IF NOT EXISTS prefix varchar(12)",
+			"ALTER TABLE access_tokens ADD COLUMN IF NOT EXISTS scopes text",
+			"UPDATE access_tokens SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex'), prefix = left(token, 8), scopes = '" + strings.Join(models.AccessTokenScopes, " ") + "'",
+			"ALTER TABLE access_tokens DROP COLUMN token",
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrateUserAccessTokens turns the former users.access_token column into AccessToken
+// rows bound to the user's first account, then drops the column
+func migrateUserAccessTokens(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("users", "access_token") {
+		return nil
+	}
+
+	log.Println("🔄 Moving user access tokens to access_tokens...")
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID          string
+			AccessToken string
+		}
+		if err := tx.Raw("SELECT id, access_token FROM users WHERE access_token IS NOT NULL AND access_token <> ''").Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			var membership models.AccountUser
+			if err := tx.Where("user_id = ?", row.ID).Order("created_at ASC").First(&membership).Error; err != nil {
+				// Tokens are bound to an account, a user without one has nothing to access
+				continue
+			}
+
+			prefix := row.AccessToken
+			if len(prefix) > 8 {
+				prefix = prefix[:8]
+			}
+			accountID := membership.AccountID
+			token := models.AccessToken{
+				OwnerID:   membership.UserID,
+				OwnerType: "User",
+				AccountID: &accountID,
+				Name:      "Personal token (migrated)",
+				Scopes:    strings.Join(models.AccessTokenScopes, " "),
+				TokenHash: security.HashToken(row.AccessToken),
+				Prefix:    prefix,
+			}
+			if err := tx.Create(&token).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropColumn("users", "access_token")
+	})
+}
+
+// addAccountUsersToInboxes makes every user of an account a member of all of its inboxes,
+// as they could see them all before inbox membership existed
+func addAccountUsersToInboxes(db *gorm.DB) error {
+	log.Println("🔄 Adding existing agents to their account inboxes...")
+	return db.Exec(`INSERT INTO inbox_members (inbox_id, user_id, created_at, updated_at)
+		SELECT inboxes.id, account_users.user_id, now(), now()
+		FROM inboxes JOIN account_users ON account_users.account_id = inboxes.account_id
+		WHERE inboxes.deleted_at IS NULL
+		ON CONFLICT DO NOTHING`).Error
+}
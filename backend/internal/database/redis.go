@@ -4,23 +4,29 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// NewRedisClient creates a new Redis client
+// NewRedisClient creates a new Redis client. Redis is optional: when it cannot be
+// reached nil is returned and features fall back to in-process implementations.
 func NewRedisClient(redisURL string) *redis.Client {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
-		log.Fatalf("Failed to parse Redis URL: %v", err)
+		log.Printf("⚠️ Warning: Failed to parse Redis URL: %v. Continuing without Redis.", err)
+		return nil
 	}
 
 	client := redis.NewClient(opt)
 
 	// Test connection
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 	if err := client.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		log.Printf("⚠️ Warning: Failed to connect to Redis: %v. Continuing without Redis.", err)
+		client.Close()
+		return nil
 	}
 
 	log.Println("✅ Redis connected successfully")
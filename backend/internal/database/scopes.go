@@ -0,0 +1,41 @@
+package database
+
+import (
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ForAccount restricts a query on an account-owned table (one with an account_id column)
+// to the rows of a single account. An invalid or empty account ID matches nothing.
+func ForAccount(accountID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		id, err := uuid.Parse(accountID)
+		if err != nil {
+			return db.Where("1 = 0")
+		}
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: "account_id"},
+			Value:  id,
+		})
+	}
+}
+
+// MessagesForAccount restricts a query on messages to the conversations of a single account
+func MessagesForAccount(accountID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		id, err := uuid.Parse(accountID)
+		if err != nil {
+			return db.Where("1 = 0")
+		}
+		conversations := db.Session(&gorm.Session{NewDB: true}).
+			Model(&models.Conversation{}).
+			Select("id").
+			Where("account_id = ?", id)
+		return db.Where(clause.Expr{
+			SQL:  "? IN (?)",
+			Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: "conversation_id"}, conversations},
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/middleware"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/security"
+	"gorm.io/gorm"
+)
+
+// accessTokenPrefix makes API tokens recognizable (and never mistaken for a JWT)
+const accessTokenPrefix = "cwt_"
+
+type AccessTokenHandler struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenHandler(db *gorm.DB) *AccessTokenHandler {
+	return &AccessTokenHandler{db: db}
+}
+
+// List lists the API tokens of the current user
+func (h *AccessTokenHandler) List(c *gin.Context) {
+	var tokens []models.AccessToken
+	if err := h.db.Where("owner_id = ? AND owner_type = ?", c.GetString("user_id"), "User").
+		Order("created_at desc").
+		Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Create creates an API token bound to the active account. The token is only
+// returned in this response.
+func (h *AccessTokenHandler) Create(c *gin.Context) {
+	var input struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes" binding:"required,min=1"`
+		ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=3650"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, scope := range input.Scopes {
+		if !middleware.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + scope, "scopes": models.AccessTokenScopes})
+			return
+		}
+	}
+
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID in context"})
+		return
+	}
+	accountID, err := uuid.Parse(c.GetString("account_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid account ID in context"})
+		return
+	}
+
+	secret, err := security.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	raw := accessTokenPrefix + secret
+
+	token := models.AccessToken{
+		OwnerID:   userID,
+		OwnerType: "User",
+		AccountID: &accountID,
+		Name:      input.Name,
+		Scopes:    strings.Join(input.Scopes, " "),
+		TokenHash: security.HashToken(raw),
+		Prefix:    raw[:len(accessTokenPrefix)+4],
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := h.db.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	token.Token = raw
+	c.JSON(http.StatusCreated, token)
+}
+
+// Delete revokes an API token of the current user
+func (h *AccessTokenHandler) Delete(c *gin.Context) {
+	result := h.db.Where("id = ? AND owner_id = ? AND owner_type = ?", c.Param("id"), c.GetString("user_id"), "User").
+		Delete(&models.AccessToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token deleted"})
+}
@@ -6,53 +6,268 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/websocket"
 	"gorm.io/gorm"
 )
 
 type AccountHandler struct {
-	db *gorm.DB
+	db    *gorm.DB
+	wsHub *websocket.Hub
 }
 
-func NewAccountHandler(db *gorm.DB) *AccountHandler {
-	return &AccountHandler{db: db}
+func NewAccountHandler(db *gorm.DB, wsHub *websocket.Hub) *AccountHandler {
+	return &AccountHandler{db: db, wsHub: wsHub}
 }
 
+// accountMembership is an account as seen by one of its users
+type accountMembership struct {
+	models.Account
+	Role   string `json:"role"`
+	Active bool   `json:"active"`
+}
+
+// accountMember is a user as seen by one of their accounts
+type accountMember struct {
+	models.User
+	Role string `json:"role"`
+}
+
+// List lists the accounts the current user belongs to, with their role in each
 func (h *AccountHandler) List(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "List accounts - TODO"})
+	var memberships []models.AccountUser
+	if err := h.db.Where("user_id = ?", c.GetString("user_id")).Order("created_at asc").Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles := make(map[uuid.UUID]string, len(memberships))
+	accountIDs := make([]uuid.UUID, 0, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.AccountID] = membership.Role
+		accountIDs = append(accountIDs, membership.AccountID)
+	}
+
+	var accounts []models.Account
+	if len(accountIDs) > 0 {
+		if err := h.db.Where("id IN ?", accountIDs).Order("name asc").Find(&accounts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	activeAccountID := c.GetString("account_id")
+	result := make([]accountMembership, 0, len(accounts))
+	for _, account := range accounts {
+		result = append(result, accountMembership{
+			Account: account,
+			Role:    roles[account.ID],
+			Active:  account.ID.String() == activeAccountID,
+		})
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
+// Create creates an account administered by the current user. The user switches to
+// it with /auth/switch_account.
 func (h *AccountHandler) Create(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Create account - TODO"})
+	var input struct {
+		Name   string `json:"name" binding:"required"`
+		Locale string `json:"locale"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Locale == "" {
+		input.Locale = "en"
+	}
+
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID in context"})
+		return
+	}
+
+	account := models.Account{Name: input.Name, Status: "active", Locale: input.Locale}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Without a domain the column stays NULL, which its unique index allows repeatedly
+		if err := tx.Omit("Domain").Create(&account).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AccountUser{AccountID: account.ID, UserID: userID, Role: roleAdministrator}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, accountMembership{Account: account, Role: roleAdministrator})
 }
 
+// Get returns the active account
 func (h *AccountHandler) Get(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Get account - TODO"})
+	var account models.Account
+	if err := h.db.First(&account, "id = ?", c.GetString("account_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
 }
 
+// Update updates the settings of the active account (admin only)
 func (h *AccountHandler) Update(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Update account - TODO"})
+	var input struct {
+		Name              *string `json:"name"`
+		Locale            *string `json:"locale"`
+		SupportEmail      *string `json:"support_email"`
+		AutoResolveTime   *int    `json:"auto_resolve_time"`
+		TwoFactorRequired *bool   `json:"two_factor_required"`
+		// Requires an enabled OIDC provider
+		PasswordLoginDisabled *bool `json:"password_login_disabled"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil && *input.Name != "" {
+		updates["name"] = *input.Name
+	}
+	if input.Locale != nil {
+		updates["locale"] = *input.Locale
+	}
+	if input.SupportEmail != nil {
+		updates["support_email"] = *input.SupportEmail
+	}
+	if input.AutoResolveTime != nil {
+		updates["auto_resolve_time"] = *input.AutoResolveTime
+	}
+	if input.TwoFactorRequired != nil {
+		updates["two_factor_required"] = *input.TwoFactorRequired
+	}
+	if input.PasswordLoginDisabled != nil {
+		if *input.PasswordLoginDisabled {
+			var providers int64
+			scoped(h.db, c).Model(&models.OIDCProvider{}).Where("enabled").Count(&providers)
+			if providers == 0 {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Configure single sign-on before disabling password login"})
+				return
+			}
+		}
+		updates["password_login_disabled"] = *input.PasswordLoginDisabled
+	}
+
+	var account models.Account
+	if err := h.db.First(&account, "id = ?", c.GetString("account_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	if len(updates) > 0 {
+		if err := h.db.Model(&account).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update account"})
+			return
+		}
+	}
+	h.db.First(&account, "id = ?", account.ID)
+
+	c.JSON(http.StatusOK, account)
 }
 
+// Delete deletes the active account (admin only). Its users lose access at once: their
+// memberships, sessions and API tokens in the account go away, and its inboxes,
+// webhooks and single sign-on stop working.
 func (h *AccountHandler) Delete(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Delete account - TODO"})
+	var account models.Account
+	if err := h.db.First(&account, "id = ?", c.GetString("account_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeSessions(tx.Where("account_id = ?", account.ID)); err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&models.AccessToken{},
+			&models.Invitation{},
+			&models.OIDCProvider{},
+			&models.Webhook{},
+			&models.Inbox{},
+			&models.AccountUser{},
+		} {
+			if err := tx.Where("account_id = ?", account.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&account).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	h.wsHub.DisconnectAccount(account.ID)
+
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	recordAudit(h.db, c, auditAccountDeleted, &userID, &account.ID, models.JSONB{"name": account.Name})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
 }
 
+// ListUsers lists the users of the account with their account role
 func (h *AccountHandler) ListUsers(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "List account users - TODO"})
-}
+	users, err := listAccountUsers(h.db, c.GetString("account_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-func (h *AccountHandler) AddUser(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Add user to account - TODO"})
+	c.JSON(http.StatusOK, users)
 }
 
+// RemoveUser removes a user from the account, along with their inbox memberships and
+// assignments in it (admin only)
 func (h *AccountHandler) RemoveUser(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "Remove user from account - TODO"})
+	var accountUser models.AccountUser
+	if err := h.db.Where("account_id = ? AND user_id = ?", c.GetString("account_id"), c.Param("user_id")).First(&accountUser).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if isLastAdministrator(h.db, &accountUser) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account needs at least one administrator"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		accountInboxes := tx.Model(&models.Inbox{}).Select("id").Where("account_id = ?", accountUser.AccountID)
+		if err := tx.Where("user_id = ? AND inbox_id IN (?)", accountUser.UserID, accountInboxes).Delete(&models.InboxMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Conversation{}).
+			Where("account_id = ? AND assignee_id = ?", accountUser.AccountID, accountUser.UserID).
+			Update("assignee_id", nil).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx.Where("account_id = ? AND user_id = ?", accountUser.AccountID, accountUser.UserID)); err != nil {
+			return err
+		}
+		return tx.Where("account_id = ? AND user_id = ?", accountUser.AccountID, accountUser.UserID).Delete(&models.AccountUser{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove user"})
+		return
+	}
+	h.wsHub.DisconnectMember(accountUser.UserID, accountUser.AccountID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User removed from account"})
 }
 
 func (h *AccountHandler) GetStats(c *gin.Context) {
-	accountIDStr := c.MustGet("account_id").(string)
-	accountID, err := uuid.Parse(accountIDStr)
-	if err != nil {
+	if _, err := uuid.Parse(c.GetString("account_id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid account ID"})
 		return
 	}
@@ -64,13 +279,54 @@ func (h *AccountHandler) GetStats(c *gin.Context) {
 		TotalMessages      int64 `json:"total_messages"`
 	}
 
-	h.db.Model(&models.Conversation{}).Where("account_id = ?", accountID).Count(&stats.TotalConversations)
-	h.db.Model(&models.Conversation{}).Where("account_id = ? AND status = ?", accountID, "open").Count(&stats.OpenConversations)
-	h.db.Model(&models.Contact{}).Where("account_id = ?", accountID).Count(&stats.TotalContacts)
-	h.db.Model(&models.Message{}).
-		Joins("JOIN conversations ON conversations.id = messages.conversation_id").
-		Where("conversations.account_id = ?", accountID).
-		Count(&stats.TotalMessages)
+	scoped(h.db, c).Model(&models.Conversation{}).Count(&stats.TotalConversations)
+	scoped(h.db, c).Model(&models.Conversation{}).Where("status = ?", "open").Count(&stats.OpenConversations)
+	scoped(h.db, c).Model(&models.Contact{}).Count(&stats.TotalContacts)
+	scopedMessages(h.db, c).Model(&models.Message{}).Count(&stats.TotalMessages)
 
 	c.JSON(http.StatusOK, stats)
 }
+
+// listAccountUsers loads the users of an account with their role in it
+func listAccountUsers(db *gorm.DB, accountID string) ([]accountMember, error) {
+	var memberships []models.AccountUser
+	if err := db.Where("account_id = ?", accountID).Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+
+	roles := make(map[uuid.UUID]string, len(memberships))
+	userIDs := make([]uuid.UUID, 0, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.UserID] = membership.Role
+		userIDs = append(userIDs, membership.UserID)
+	}
+
+	members := make([]accountMember, 0, len(userIDs))
+	if len(userIDs) == 0 {
+		return members, nil
+	}
+
+	var users []models.User
+	if err := db.Where("id IN ?", userIDs).Order("name asc").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		user.PasswordHash = ""
+		members = append(members, accountMember{User: user, Role: roles[user.ID]})
+	}
+	return members, nil
+}
+
+// isLastAdministrator reports whether demoting or removing this membership would leave
+// its account without an administrator
+func isLastAdministrator(db *gorm.DB, accountUser *models.AccountUser) bool {
+	if accountUser.Role != roleAdministrator {
+		return false
+	}
+
+	var others int64
+	db.Model(&models.AccountUser{}).
+		Where("account_id = ? AND role = ? AND user_id <> ?", accountUser.AccountID, roleAdministrator, accountUser.UserID).
+		Count(&others)
+	return others == 0
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	auditUserLocked   = "user.locked"
+	auditUserUnlocked = "user.unlocked"
+	// A user created on first single sign-on
+	auditUserProvisioned = "user.provisioned"
+	auditAccountDeleted  = "account.deleted"
+)
+
+// recordAudit stores an audit log entry for the request
+func recordAudit(db *gorm.DB, c *gin.Context, action string, userID, accountID *uuid.UUID, metadata models.JSONB) {
+	entry := models.AuditLog{
+		AccountID: accountID,
+		UserID:    userID,
+		Action:    action,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Metadata:  metadata,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record audit log %s: %v", action, err)
+	}
+}
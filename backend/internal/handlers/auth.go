@@ -1,32 +1,48 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/nakamura/chatwoot-go/internal/config"
+	"github.com/nakamura/chatwoot-go/internal/mailer"
 	"github.com/nakamura/chatwoot-go/internal/middleware"
 	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/presence"
+	"github.com/nakamura/chatwoot-go/internal/ratelimit"
+	"github.com/nakamura/chatwoot-go/internal/security"
+	"github.com/nakamura/chatwoot-go/internal/websocket"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db      *gorm.DB
+	wsHub   *websocket.Hub
+	cfg     *config.Config
+	mailer  mailer.Mailer
+	limiter ratelimit.Limiter
 }
 
-func NewAuthHandler(db *gorm.DB, cfg *config.Config) *AuthHandler {
-	return &AuthHandler{db: db, cfg: cfg}
+func NewAuthHandler(db *gorm.DB, wsHub *websocket.Hub, cfg *config.Config, m mailer.Mailer, limiter ratelimit.Limiter) *AuthHandler {
+	return &AuthHandler{db: db, wsHub: wsHub, cfg: cfg, mailer: m, limiter: limiter}
 }
 
+// passwordResetTTL is how long a password reset link stays valid
+const passwordResetTTL = time.Hour
+
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email     string `json:"email" binding:"required,email"`
+	Password  string `json:"password" binding:"required"`
+	AccountID string `json:"account_id"` // optional, defaults to the oldest membership
 }
 
 type RegisterRequest struct {
@@ -36,8 +52,10 @@ type RegisterRequest struct {
 }
 
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	ExpiresIn    int          `json:"expires_in,omitempty"` // access token lifetime in seconds
+	User         *models.User `json:"user"`
 }
 
 // Login authenticates a user
@@ -49,48 +67,76 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Login attempt for email: %s", req.Email)
+	email := normalizeEmail(req.Email)
+	if !h.throttleLogin(c, email) {
+		return
+	}
 
-	// Find user by email
+	// Unknown emails go through the same lockout and password check as users, so that
+	// the response does not tell whether the email is registered
 	var user models.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		log.Printf("Login error: User not found for email %s: %v", req.Email, err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if err := h.db.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+		if h.checkLockout(c, h.unknownEmailFailures(email)) {
+			rejectUnknownPassword(req.Password)
+			h.registerUnknownEmailFailure(email)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		}
 		return
 	}
 
-	log.Printf("User found: %s, hash length: %d", user.Email, len(user.PasswordHash))
+	if !h.checkLockout(c, loginFailures{
+		Attempts:     user.FailedLoginAttempts,
+		LastFailedAt: user.LastFailedLoginAt,
+		LockedUntil:  user.LockedUntil,
+	}) {
+		return
+	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		log.Printf("Login error: Password mismatch for %s: %v", req.Email, err)
+		log.Printf("Login error: Password mismatch for user %s", user.ID)
+		h.registerFailedLogin(c, &user)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	log.Printf("Password verified for user: %s", user.Email)
+	h.clearFailedLogins(&user)
 
-	// Get user's first account (for simplicity)
+	// Sign in to the requested account, or to the user's oldest membership
+	query := h.db.Where("user_id = ?", user.ID)
+	if req.AccountID != "" {
+		query = query.Where("account_id = ?", req.AccountID)
+	}
 	var accountUser models.AccountUser
-	if err := h.db.Where("user_id = ?", user.ID).First(&accountUser).Error; err != nil {
+	if err := query.Order("created_at asc").First(&accountUser).Error; err != nil {
+		if req.AccountID != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No access to this account"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "User has no associated account"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.generateToken(&user, accountUser.AccountID)
+	// Accounts can require their identity provider instead of passwords
+	if passwordLoginDisabled(h.db, accountUser.AccountID) {
+		respondSSORequired(c, accountUser.AccountID)
+		return
+	}
+
+	// Users with two-factor authentication continue with /auth/2fa/verify
+	if user.TwoFactorEnabled {
+		respondWithChallenge(c, h.cfg, &user, accountUser.AccountID, authMethodPassword)
+		return
+	}
+
+	// Open a session and issue its tokens
+	response, err := startSession(h.db, h.cfg, c, &user, accountUser.AccountID, accountUser.Role, authMethodPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Hide password hash
-	user.PasswordHash = ""
-
-	c.JSON(http.StatusOK, LoginResponse{
-		Token: token,
-		User:  &user,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 // Register creates a new user account
@@ -102,8 +148,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Check if user already exists
+	req.Email = normalizeEmail(req.Email)
 	var existingUser models.User
-	if err := h.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := h.db.Where("LOWER(email) = ?", req.Email).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
 		return
 	}
@@ -161,20 +208,14 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.generateToken(&user, account.ID)
+	// Open a session and issue its tokens
+	response, err := startSession(h.db, h.cfg, c, &user, account.ID, roleAdministrator, authMethodPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	// Hide password hash
-	user.PasswordHash = ""
-
-	c.JSON(http.StatusCreated, LoginResponse{
-		Token: token,
-		User:  &user,
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 // GetProfile returns the current user's profile
@@ -193,12 +234,6 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	// Ensure AccessToken exists (migration for existing users)
-	if user.AccessToken == "" {
-		user.AccessToken = uuid.New().String()
-		h.db.Model(&user).Update("access_token", user.AccessToken)
-	}
-
 	// DEBUG: Log loaded accounts
 	log.Printf("GetProfile - User %s, Loaded Accounts via Preload: %d", user.Email, len(user.Accounts))
 
@@ -273,7 +308,8 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	if req.Email != "" {
 		var existingUser models.User
 		// Check if any OTHER user has this email
-		if err := h.db.Where("email = ? AND id != ?", req.Email, userID).First(&existingUser).Error; err == nil {
+		req.Email = normalizeEmail(req.Email)
+		if err := h.db.Where("LOWER(email) = ? AND id != ?", req.Email, userID).First(&existingUser).Error; err == nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
 			return
 		}
@@ -294,8 +330,9 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	user.PasswordHash = ""
 
 	// Generate new token with updated user data
-	accountID := c.MustGet("account_id").(uuid.UUID)
-	newToken, err := h.generateToken(&user, accountID)
+	accountID, _ := uuid.Parse(c.GetString("account_id"))
+	sessionID, _ := uuid.Parse(c.GetString("session_id"))
+	newToken, err := signToken(h.cfg, &user, accountID, c.GetString("role"), sessionID)
 	if err != nil {
 		// Log error but return user at least
 		// If token generation fails, return the user without a token
@@ -311,25 +348,71 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	})
 }
 
-// ResetAccessToken generates a new access token for the user
-func (h *AuthHandler) ResetAccessToken(c *gin.Context) {
-	// We need the account ID to verify permission if needed, but for now user rotates their own token
-	// accountIDStr := c.MustGet("account_id").(string)
+// SwitchAccount moves the current session to another account the user is a member of
+// and issues an access token for it. The refresh token is unchanged.
+func (h *AuthHandler) SwitchAccount(c *gin.Context) {
+	var req struct {
+		AccountID string `json:"account_id" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
 
-	userIDStr := c.MustGet("user_id").(string)
-	userID, err := uuid.Parse(userIDStr)
+	accountID, _ := uuid.Parse(req.AccountID)
+	role, err := middleware.AccountRole(h.db, user.ID, accountID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "No access to this account"})
 		return
 	}
-	newToken := uuid.New().String()
 
-	if err := h.db.Model(&models.User{}).Where("id = ?", userID).Update("access_token", newToken).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset token"})
+	sessionID, _ := uuid.Parse(c.GetString("session_id"))
+
+	var session models.Session
+	if err := h.db.First(&session, "id = ?", sessionID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session not found"})
+		return
+	}
+	// An identity provider only vouches for its own account: an SSO session switches
+	// back into that account only, and a password session cannot enter an account
+	// that requires single sign-on
+	ssoAccount := session.SSOAccountID != nil && *session.SSOAccountID == accountID
+	if !ssoAccount && passwordLoginDisabled(h.db, accountID) {
+		respondSSORequired(c, accountID)
+		return
+	}
+	if session.AuthMethod == authMethodSSO && !ssoAccount {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":      "Sign in again to enter this account",
+			"code":       "reauthentication_required",
+			"account_id": accountID,
+		})
+		return
+	}
+
+	if err := h.db.Model(&models.Session{}).Where("id = ?", sessionID).Update("account_id", accountID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to switch account"})
+		return
+	}
+
+	token, err := signToken(h.cfg, &user, accountID, role, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"access_token": newToken})
+	user.PasswordHash = ""
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:     token,
+		ExpiresIn: int(accessTokenTTL.Seconds()),
+		User:      &user,
+	})
 }
 
 // ChangePassword changes the user's password
@@ -370,13 +453,40 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// Update password
-	if err := h.db.Model(&user).Update("password_hash", string(hashedPassword)).Error; err != nil {
+	// Update password and sign out every session, including this one
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password_hash":       string(hashedPassword),
+			"password_changed_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return invalidateUserTokens(tx, user.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
+	h.wsHub.DisconnectUser(user.ID)
+
+	// Continue on a fresh session
+	if err := h.db.First(&user, "id = ?", user.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch updated user"})
+		return
+	}
+	accountID, _ := uuid.Parse(c.GetString("account_id"))
+	response, err := startSession(h.db, h.cfg, c, &user, accountID, c.GetString("role"), authMethodPassword)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Password updated successfully",
+		"token":         response.Token,
+		"refresh_token": response.RefreshToken,
+		"expires_in":    response.ExpiresIn,
+	})
 }
 
 // UpdateAvailability updates user's availability status
@@ -390,6 +500,7 @@ func (h *AuthHandler) UpdateAvailability(c *gin.Context) {
 
 	var req struct {
 		Availability string `json:"availability" binding:"required,oneof=online busy offline"`
+		AutoOffline  *bool  `json:"auto_offline"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -397,43 +508,190 @@ func (h *AuthHandler) UpdateAvailability(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Model(&models.User{}).Where("id = ?", userID).Update("availability", req.Availability).Error; err != nil {
+	// A chosen availability replaces the one to restore on reconnect
+	updates := map[string]interface{}{"availability": req.Availability, "auto_away_from": ""}
+	if req.AutoOffline != nil {
+		updates["auto_offline"] = *req.AutoOffline
+	}
+	if err := h.db.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update availability"})
 		return
 	}
+	presence.Announce(h.db, h.wsHub, userID)
 
-	c.JSON(http.StatusOK, gin.H{"availability": req.Availability})
+	var user models.User
+	h.db.Select("availability", "auto_offline").First(&user, "id = ?", userID)
+	c.JSON(http.StatusOK, gin.H{"availability": user.Availability, "auto_offline": user.AutoOffline})
 }
 
-// ForgotPassword initiates password reset
+// ForgotPassword emails a password reset link. The response does not reveal whether
+// the email belongs to a user.
 func (h *AuthHandler) ForgotPassword(c *gin.Context) {
-	// TODO: Implement email sending
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset email sent"})
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := normalizeEmail(req.Email)
+
+	if !h.allow(c, "forgot_password:ip:"+c.ClientIP(), 10, time.Hour) ||
+		!h.allow(c, "forgot_password:email:"+email, 3, time.Hour) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests, try again later"})
+		return
+	}
+
+	response := gin.H{"message": "If the email is registered, a password reset link has been sent"}
+
+	var user models.User
+	if err := h.db.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := security.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate reset token"})
+		return
+	}
+
+	resetToken := models.PasswordResetToken{
+		UserID:      user.ID,
+		TokenHash:   security.HashToken(token),
+		ExpiresAt:   time.Now().Add(passwordResetTTL),
+		RequestedIP: c.ClientIP(),
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// Only the latest link works
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&resetToken).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(h.cfg.FrontendURL, "/"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. Choose a new password here:\n%s\n\nThe link expires in %d minutes and can be used once. If you did not ask for it, ignore this email.\n",
+			user.Name, link, int(passwordResetTTL.Minutes()),
+		),
+	}
+
+	// In the background, so that the response time does not tell registered emails apart
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := h.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, response)
 }
 
-// ResetPassword resets user password
+// ResetPassword sets a new password using a reset token. Every session issued before
+// the reset stops working.
 func (h *AuthHandler) ResetPassword(c *gin.Context) {
-	// TODO: Implement password reset with token
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.allow(c, "reset_password:ip:"+c.ClientIP(), 10, time.Hour) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, try again later"})
+		return
+	}
+
+	var resetToken models.PasswordResetToken
+	if err := h.db.Where("token_hash = ?", security.HashToken(req.Token)).First(&resetToken).Error; err != nil ||
+		resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	errTokenUsed := errors.New("reset token already used")
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errTokenUsed
+		}
+
+		// A reset also lifts a lockout
+		if err := tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).Updates(map[string]interface{}{
+			"password_hash":         string(hashedPassword),
+			"password_changed_at":   now,
+			"failed_login_attempts": 0,
+			"last_failed_login_at":  nil,
+			"locked_until":          nil,
+		}).Error; err != nil {
+			return err
+		}
+		return invalidateUserTokens(tx, resetToken.UserID)
+	})
+	if errors.Is(err, errTokenUsed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	h.wsHub.DisconnectUser(resetToken.UserID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset successful"})
 }
 
-// ListUsers lists all users (admin only)
+// allow applies a rate limit. When the limiter is unavailable requests are let through.
+func (h *AuthHandler) allow(c *gin.Context, key string, limit int, window time.Duration) bool {
+	if h.limiter == nil {
+		return true
+	}
+
+	ok, err := h.limiter.Allow(c.Request.Context(), key, limit, window)
+	if err != nil {
+		log.Printf("Rate limiter error for %s: %v", key, err)
+		return true
+	}
+	return ok
+}
+
+// ListUsers lists the users of the current account with their account role (admin only)
 func (h *AuthHandler) ListUsers(c *gin.Context) {
-	var users []models.User
-	if err := h.db.Find(&users).Error; err != nil {
+	users, err := listAccountUsers(h.db, c.GetString("account_id"))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
 
-	// Hide password hashes
-	for i := range users {
-		users[i].PasswordHash = ""
-	}
-
 	c.JSON(http.StatusOK, users)
 }
 
-// UpdateUserRole updates a user's role (admin only)
+// UpdateUserRole updates a user's role in the current account (admin only)
 func (h *AuthHandler) UpdateUserRole(c *gin.Context) {
 	userID := c.Param("id")
 
@@ -446,27 +704,27 @@ func (h *AuthHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Model(&models.User{}).Where("id = ?", userID).Update("role", req.Role).Error; err != nil {
+	// Only users of the current account can be managed
+	var accountUser models.AccountUser
+	if err := h.db.Where("account_id = ? AND user_id = ?", c.GetString("account_id"), userID).First(&accountUser).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if req.Role != roleAdministrator && isLastAdministrator(h.db, &accountUser) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account needs at least one administrator"})
+		return
+	}
+
+	previousRole := accountUser.Role
+	if err := h.db.Model(&accountUser).Update("role", req.Role).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
 		return
 	}
+	// Open connections joined their rooms with the previous role
+	if req.Role != previousRole {
+		h.wsHub.DisconnectMember(accountUser.UserID, accountUser.AccountID)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
 }
-
-// generateToken generates a JWT token for a user
-func (h *AuthHandler) generateToken(user *models.User, accountID uuid.UUID) (string, error) {
-	claims := middleware.Claims{
-		UserID:    user.ID,
-		Email:     user.Email,
-		Role:      user.Role,
-		AccountID: accountID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.cfg.JWTSecret))
-}
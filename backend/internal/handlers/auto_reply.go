@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nakamura/chatwoot-go/internal/channels"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/schedule"
+	"github.com/nakamura/chatwoot-go/internal/websocket"
+	"gorm.io/gorm"
+)
+
+// Sender types of messages
+const (
+	senderTypeUser    = "User"
+	senderTypeContact = "Contact"
+	senderTypeBot     = "Bot"
+)
+
+// templateVariable matches {{ variable }} placeholders such as {{contact.name}}
+var templateVariable = regexp.MustCompile(`\{\{\s*([a-z_]+\.[a-z_]+)\s*\}\}`)
+
+// autoReplier sends automated messages (greeting, out-of-office) on behalf of an inbox
+type autoReplier struct {
+	db       *gorm.DB
+	wsHub    *websocket.Hub
+	channels *channels.Registry
+}
+
+// replyTo sends the automated replies a new contact message calls for. It runs outside
+// of the webhook request, as delivery through the channel may be slow.
+func (a *autoReplier) replyTo(inbox models.Inbox, contact models.Contact, conversation models.Conversation, isNew bool) {
+	if isNew {
+		a.sendGreeting(&inbox, &contact, &conversation)
+	}
+	a.sendOutOfOffice(&inbox, &contact, &conversation)
+}
+
+// sendGreeting welcomes the contact of a conversation that was just created by a channel
+func (a *autoReplier) sendGreeting(inbox *models.Inbox, contact *models.Contact, conversation *models.Conversation) {
+	if !inbox.GreetingEnabled || inbox.GreetingMessage == "" {
+		return
+	}
+
+	a.sendAutomated(inbox, contact, conversation, inbox.GreetingMessage, "greeting")
+}
+
+// sendOutOfOffice replies with the inbox OutOfOfficeMessage when a contact writes
+// while the inbox is closed, at most once per conversation per closed period.
+func (a *autoReplier) sendOutOfOffice(inbox *models.Inbox, contact *models.Contact, conversation *models.Conversation) {
+	if !inbox.WorkingHoursEnabled || inbox.OutOfOfficeMessage == "" {
+		return
+	}
+
+	sched, err := schedule.Load(a.db, inbox.ID)
+	if err != nil {
+		log.Printf("Out of office: failed to load schedule for inbox %s: %v", inbox.ID, err)
+		return
+	}
+	if sched == nil {
+		return
+	}
+
+	now := time.Now()
+	if sched.IsOpen(now) {
+		return
+	}
+
+	// A closed period is identified by the instant the inbox opens again
+	period := "never"
+	if nextOpen, ok := sched.NextOpen(now); ok {
+		period = nextOpen.UTC().Format(time.RFC3339)
+	}
+
+	// Claim the period before sending, so that messages arriving together get a single reply
+	claim := a.db.Model(&models.Conversation{}).
+		Where("id = ? AND COALESCE(additional_attributes ->> 'out_of_office_period', '') <> ?", conversation.ID, period).
+		Update("additional_attributes", gorm.Expr("jsonb_set(COALESCE(additional_attributes, '{}'::jsonb), '{out_of_office_period}', to_jsonb(?::text))", period))
+	if claim.Error != nil {
+		log.Printf("Out of office: failed to update conversation %s: %v", conversation.ID, claim.Error)
+		return
+	}
+	if claim.RowsAffected == 0 {
+		return
+	}
+
+	a.sendAutomated(inbox, contact, conversation, inbox.OutOfOfficeMessage, "out_of_office")
+}
+
+// renderTemplate replaces the template variables of an automated message.
+// Supported: contact.name, contact.first_name, contact.email, contact.phone_number, inbox.name, account.name.
+func (a *autoReplier) renderTemplate(content string, inbox *models.Inbox, contact *models.Contact) string {
+	if !strings.Contains(content, "{{") {
+		return content
+	}
+
+	firstName := contact.Name
+	if fields := strings.Fields(contact.Name); len(fields) > 0 {
+		firstName = fields[0]
+	}
+
+	variables := map[string]string{
+		"contact.name":         contact.Name,
+		"contact.first_name":   firstName,
+		"contact.email":        contact.Email,
+		"contact.phone_number": contact.PhoneNumber,
+		"inbox.name":           inbox.Name,
+	}
+	if strings.Contains(content, "account.") {
+		var account models.Account
+		if err := a.db.Select("name").First(&account, "id = ?", inbox.AccountID).Error; err == nil {
+			variables["account.name"] = account.Name
+		}
+	}
+
+	// Unknown variables render as empty strings
+	return templateVariable.ReplaceAllStringFunc(content, func(match string) string {
+		return variables[templateVariable.FindStringSubmatch(match)[1]]
+	})
+}
+
+// sendAutomated stores an outgoing bot-authored message, delivers it through the channel and broadcasts it
+func (a *autoReplier) sendAutomated(inbox *models.Inbox, contact *models.Contact, conversation *models.Conversation, content, kind string) {
+	content = a.renderTemplate(content, inbox, contact)
+
+	message := models.Message{
+		ConversationID:    conversation.ID,
+		SenderType:        senderTypeBot,
+		Content:           content,
+		ContentType:       "text",
+		MessageType:       "outgoing",
+		Status:            "sent",
+		ContentAttributes: models.JSONB{"automation": kind},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sourceID, err := a.channels.SendText(ctx, inbox, contact, content)
+	if err != nil && !errors.Is(err, channels.ErrNoProvider) {
+		log.Printf("Failed to deliver %s message to conversation %s: %v", kind, conversation.ID, err)
+		message.Status = "failed"
+	}
+	message.SourceID = sourceID
+
+	if err := a.db.Create(&message).Error; err != nil {
+		log.Printf("Failed to store %s message: %v", kind, err)
+		return
+	}
+
+	if a.wsHub != nil {
+		a.wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "message.created", message)
+	}
+}
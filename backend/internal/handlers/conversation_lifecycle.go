@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/websocket"
+	"gorm.io/gorm"
+)
+
+// Conversation statuses
+const (
+	statusOpen     = "open"
+	statusResolved = "resolved"
+	statusPending  = "pending"
+	statusSnoozed  = "snoozed"
+)
+
+// recordActivity stores an activity message (status transitions, etc.) and broadcasts it
+func recordActivity(db *gorm.DB, wsHub *websocket.Hub, conversation *models.Conversation, content string) {
+	message := models.Message{
+		ConversationID: conversation.ID,
+		Content:        content,
+		ContentType:    "text",
+		MessageType:    "activity",
+		Status:         "sent",
+	}
+	if err := db.Create(&message).Error; err != nil {
+		log.Printf("Failed to record activity for conversation %s: %v", conversation.ID, err)
+		return
+	}
+
+	if wsHub != nil {
+		wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "message.created", message)
+	}
+}
+
+// conversationForIncoming returns the conversation an incoming contact message belongs to:
+//   - open and pending conversations keep receiving messages (pending ones stay with their bot/queue)
+//   - snoozed conversations wake up and are reopened
+//   - the latest resolved conversation is reopened when the inbox allows messages after
+//     resolution and it was resolved within the inbox reopen window
+//   - otherwise a new conversation is started
+//
+// isNew reports whether a conversation was created.
+func (h *IncomingWebhookHandler) conversationForIncoming(inbox *models.Inbox, contact *models.Contact) (conversation models.Conversation, isNew bool, err error) {
+	now := time.Now()
+
+	err = h.db.
+		Where("inbox_id = ? AND contact_id = ? AND status IN ?", inbox.ID, contact.ID, []string{statusOpen, statusPending, statusSnoozed}).
+		Order("last_activity_at desc").
+		First(&conversation).Error
+	if err == nil {
+		if conversation.Status == statusSnoozed {
+			h.db.Model(&conversation).Updates(map[string]interface{}{
+				"status":        statusOpen,
+				"snoozed_until": nil,
+			})
+			recordActivity(h.db, h.wsHub, &conversation, fmt.Sprintf("Conversation was unsnoozed by a new message from %s", contact.Name))
+		}
+		return conversation, false, nil
+	}
+
+	var resolved models.Conversation
+	hasResolved := h.db.
+		Where("inbox_id = ? AND contact_id = ? AND status = ?", inbox.ID, contact.ID, statusResolved).
+		Order("last_activity_at desc").
+		First(&resolved).Error == nil
+
+	if hasResolved && inbox.AllowMessagesAfterResolved && withinReopenWindow(inbox, resolved.LastActivityAt, now) {
+		h.db.Model(&resolved).Update("status", statusOpen)
+		recordActivity(h.db, h.wsHub, &resolved, fmt.Sprintf("Conversation was reopened by a new message from %s", contact.Name))
+		if h.sla != nil {
+			h.sla.Restart(&resolved)
+		}
+		return resolved, false, nil
+	}
+
+	conversation = models.Conversation{
+		InboxID:        inbox.ID,
+		ContactID:      contact.ID,
+		AccountID:      inbox.AccountID,
+		Status:         statusOpen,
+		LastActivityAt: now,
+	}
+	if err := h.db.Create(&conversation).Error; err != nil {
+		return conversation, false, err
+	}
+	if h.sla != nil {
+		h.sla.Apply(&conversation)
+	}
+
+	if hasResolved {
+		recordActivity(h.db, h.wsHub, &conversation, fmt.Sprintf("New conversation started; previous conversation #%d was resolved", resolved.DisplayID))
+	}
+	return conversation, true, nil
+}
+
+// withinReopenWindow reports whether a conversation resolved at resolvedAt may still be reopened.
+// A window of 0 hours reopens regardless of age.
+func withinReopenWindow(inbox *models.Inbox, resolvedAt, now time.Time) bool {
+	if inbox.ReopenWindowHours <= 0 {
+		return true
+	}
+	return now.Sub(resolvedAt) <= time.Duration(inbox.ReopenWindowHours)*time.Hour
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	ws "github.com/nakamura/chatwoot-go/internal/websocket"
+)
+
+// HandleEventStream streams the events of the hub as Server-Sent Events, for clients
+// and networks where WebSockets are not an option. It is authenticated like /cable.
+// The rooms are chosen with repeated room query params (notifications by default) and
+// must all be allowed. The ID of each event is its sequence number, so the
+// Last-Event-ID header browsers send when reconnecting (or the last_event_id query
+// param) replays the events missed in between.
+func (h *WebSocketHandler) HandleEventStream(c *gin.Context) {
+	claims, role, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+
+	client := &ws.Client{
+		ID:        uuid.New(),
+		UserID:    claims.UserID,
+		AccountID: claims.AccountID,
+		SessionID: claims.SessionID,
+		Role:      role,
+		Send:      make(chan []byte, 256),
+		Hub:       h.hub,
+		Rooms:     make(map[string]bool),
+		Protocol:  ws.SSE{},
+		Done:      make(chan struct{}),
+	}
+	client.ResolveRoom = func(room string) []string {
+		return h.resolveRoom(client, room)
+	}
+
+	rooms := c.QueryArray("room")
+	if len(rooms) == 0 {
+		rooms = []string{ws.NotificationsRoom}
+	}
+	for _, room := range rooms {
+		if !client.Subscribe(room, room) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No access to room " + room})
+			return
+		}
+	}
+
+	var lastSeq *uint64
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	if lastEventID != "" {
+		seq, err := strconv.ParseUint(strings.TrimSpace(lastEventID), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		lastSeq = &seq
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx must not buffer the stream
+	c.Status(http.StatusOK)
+
+	controller := http.NewResponseController(c.Writer)
+	write := func(data []byte) bool {
+		controller.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := c.Writer.Write(data); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+	if !write(client.Protocol.Welcome()) {
+		return
+	}
+
+	if claims.ExpiresAt != nil {
+		client.CloseAt(claims.ExpiresAt.Time, ws.CloseTokenExpired, "token expired")
+	}
+	h.hub.Register <- client
+	// Before the connection can close, so that Disconnected always comes after
+	if h.presence != nil {
+		h.presence.Connected(client.UserID, client.ID)
+	}
+
+	// The replay waits for the loop below to drain the send buffer
+	resumed := make(chan struct{})
+	go func() {
+		defer close(resumed)
+		client.Resume(lastSeq)
+	}()
+
+	defer func() {
+		client.Close(websocket.CloseNormalClosure, "")
+		<-resumed
+		h.hub.Unregister <- client
+		if h.presence != nil {
+			h.presence.Disconnected(client.UserID, client.ID)
+		}
+	}()
+
+	heartbeat := time.NewTicker(client.Protocol.HeartbeatInterval())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case frame, ok := <-client.Send:
+			if !ok || !write(frame) {
+				return
+			}
+		case <-heartbeat.C:
+			if !write(client.Protocol.Heartbeat()) {
+				return
+			}
+		case <-client.Done:
+			// Tell the client why, e.g. to refresh its token before reconnecting
+			code, reason := client.CloseStatus()
+			data, _ := json.Marshal(map[string]interface{}{"code": code, "reason": reason})
+			write([]byte("event: close\ndata: " + string(data) + "\n\n"))
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -1,14 +1,23 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/channels"
+	"github.com/nakamura/chatwoot-go/internal/config"
 	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/notifications"
+	"github.com/nakamura/chatwoot-go/internal/sla"
 	"github.com/nakamura/chatwoot-go/internal/websocket"
 	"gorm.io/gorm"
 )
@@ -18,24 +27,39 @@ import (
 // ---------------------------------------------------------------------
 
 type ConversationHandler struct {
-	db    *gorm.DB
-	wsHub *websocket.Hub
+	db            *gorm.DB
+	wsHub         *websocket.Hub
+	sla           *sla.Service
+	notifications *notifications.Service
 }
 
-func NewConversationHandler(db *gorm.DB, wsHub *websocket.Hub) *ConversationHandler {
-	return &ConversationHandler{db: db, wsHub: wsHub}
+func NewConversationHandler(db *gorm.DB, wsHub *websocket.Hub, slaService *sla.Service, notifier *notifications.Service) *ConversationHandler {
+	return &ConversationHandler{db: db, wsHub: wsHub, sla: slaService, notifications: notifier}
+}
+
+// priorityOrder sorts conversations from urgent to none
+const priorityOrder = "CASE priority WHEN 'urgent' THEN 4 WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END DESC"
+
+// validPriority reports whether p is a conversation priority
+func validPriority(p string) bool {
+	switch p {
+	case "urgent", "high", "medium", "low", "none":
+		return true
+	}
+	return false
 }
 
 // List conversations
+// Query params: status, inbox_id, priority (comma separated), sort_by (last_activity_at, created_at, priority)
 func (h *ConversationHandler) List(c *gin.Context) {
-	accountID := c.GetString("account_id")
 	status := c.Query("status")
 	inboxID := c.Query("inbox_id")
+	priority := c.Query("priority")
 
-	query := h.db.
+	query := scoped(h.db, c).
 		Preload("Contact").
 		Preload("Inbox").
-		Where("account_id = ?", accountID) // Basic security filter
+		Scopes(visibleInboxes(h.db, c))
 
 	if status != "" {
 		query = query.Where("status = ?", status)
@@ -47,8 +71,28 @@ func (h *ConversationHandler) List(c *gin.Context) {
 		query = query.Where("inbox_id = ?", inboxID)
 	}
 
+	if priority != "" {
+		priorities := strings.Split(priority, ",")
+		for _, p := range priorities {
+			if !validPriority(p) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority: " + p})
+				return
+			}
+		}
+		query = query.Where("priority IN ?", priorities)
+	}
+
+	switch c.Query("sort_by") {
+	case "priority":
+		query = query.Order(priorityOrder).Order("last_activity_at desc")
+	case "created_at":
+		query = query.Order("created_at desc")
+	default:
+		query = query.Order("last_activity_at desc")
+	}
+
 	var conversations []models.Conversation
-	if err := query.Order("last_activity_at desc").Find(&conversations).Error; err != nil {
+	if err := query.Find(&conversations).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -64,7 +108,8 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 	var input struct {
 		ContactID string `json:"contact_id" binding:"required"`
 		InboxID   string `json:"inbox_id"`
-		Status    string `json:"status"`
+		Status    string `json:"status" binding:"omitempty,oneof=open resolved pending snoozed"`
+		Priority  string `json:"priority" binding:"omitempty,oneof=urgent high medium low none"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -80,7 +125,7 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 
 	// Validate Contact
 	var contact models.Contact
-	if err := h.db.Where("id = ? AND account_id = ?", contactUUID, accountID).First(&contact).Error; err != nil {
+	if err := scoped(h.db, c).Where("id = ?", contactUUID).First(&contact).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
 		return
 	}
@@ -88,11 +133,15 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 	// Resolve Inbox
 	var inboxID uuid.UUID
 	if input.InboxID != "" {
-		inboxID, _ = uuid.Parse(input.InboxID)
+		inboxID, err = uuid.Parse(input.InboxID)
+		if err != nil || !canAccessInbox(h.db, c, inboxID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this inbox"})
+			return
+		}
 	} else {
 		// Attempt to find existing inbox or default
 		var inbox models.Inbox
-		if err := h.db.Where("account_id = ?", accountID).First(&inbox).Error; err != nil {
+		if err := scoped(h.db, c).Scopes(visibleInboxIDs(h.db, c)).First(&inbox).Error; err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "No inbox available. Create one first."})
 			return
 		}
@@ -116,12 +165,23 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 	if input.Status != "" {
 		conversation.Status = input.Status
 	}
+	if input.Priority != "" {
+		conversation.Priority = input.Priority
+	}
 
 	if err := h.db.Create(&conversation).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if h.sla != nil {
+		h.sla.Apply(&conversation)
+	}
+	if h.notifications != nil {
+		creatorID, _ := uuid.Parse(c.GetString("user_id"))
+		h.notifications.ConversationCreated(&conversation, &creatorID)
+	}
+
 	// Fetch complete object for response
 	h.db.Preload("Contact").Preload("Inbox").First(&conversation, conversation.ID)
 
@@ -130,10 +190,9 @@ func (h *ConversationHandler) Create(c *gin.Context) {
 
 func (h *ConversationHandler) Get(c *gin.Context) {
 	id := c.Param("id")
-	accountID := c.GetString("account_id")
 
 	var conversation models.Conversation
-	if err := h.db.Preload("Contact").Preload("Inbox").Where("id = ? AND account_id = ?", id, accountID).First(&conversation).Error; err != nil {
+	if err := scoped(h.db, c).Preload("Contact").Preload("Inbox").Where("id = ?", id).Scopes(visibleInboxes(h.db, c)).First(&conversation).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
 		return
 	}
@@ -142,67 +201,270 @@ func (h *ConversationHandler) Get(c *gin.Context) {
 }
 
 func (h *ConversationHandler) Update(c *gin.Context) {
-	// Implementation simplified
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	var conversation models.Conversation
+	if !h.findConversation(c, &conversation) {
+		return
+	}
+
+	var input struct {
+		Priority *string `json:"priority" binding:"omitempty,oneof=urgent high medium low none"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Priority != nil {
+		updates["priority"] = *input.Priority
+	}
+
+	if len(updates) > 0 {
+		if err := h.db.Model(&conversation).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// SLA policies may target a priority: deadlines follow the new one
+	if input.Priority != nil && h.sla != nil {
+		conversation.Priority = *input.Priority
+		h.sla.Reapply(&conversation)
+	}
+
+	h.db.Preload("Contact").Preload("Inbox").First(&conversation, "id = ?", conversation.ID)
+
+	if h.wsHub != nil {
+		h.wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "conversation.updated", conversation)
+	}
+
+	c.JSON(http.StatusOK, conversation)
 }
 
+// Delete removes a conversation of the account
 func (h *ConversationHandler) Delete(c *gin.Context) {
-	// Implementation simplified
+	var conversation models.Conversation
+	if !h.findConversation(c, &conversation) {
+		return
+	}
+
+	if err := h.db.Delete(&conversation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// findConversation loads the conversation of the :id path param within the current
+// account and the user's inboxes, answering 404 otherwise
+func (h *ConversationHandler) findConversation(c *gin.Context, conversation *models.Conversation) bool {
+	if err := scoped(h.db, c).Where("id = ?", c.Param("id")).Scopes(visibleInboxes(h.db, c)).First(conversation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return false
+	}
+	return true
+}
+
 func (h *ConversationHandler) Assign(c *gin.Context) {
-	id := c.Param("id")
+	var conversation models.Conversation
+	if !h.findConversation(c, &conversation) {
+		return
+	}
+
 	var input struct {
 		UserID string `json:"user_id"`
 	}
 	c.ShouldBindJSON(&input)
 
 	//Logic to update assignee_id
-	updater := h.db.Model(&models.Conversation{}).Where("id = ?", id)
+	updater := h.db.Model(&conversation)
 	if input.UserID == "" {
 		updater.Update("assignee_id", nil)
 	} else {
-		updater.Update("assignee_id", input.UserID)
+		// The assignee must be an agent of the same account who can open the conversation
+		assigneeID, err := uuid.Parse(input.UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		var assignee models.AccountUser
+		if err := h.db.Where("account_id = ? AND user_id = ?", conversation.AccountID, assigneeID).First(&assignee).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Assignee does not belong to this account"})
+			return
+		}
+		if !canAccessInboxAs(h.db, assigneeID, conversation.AccountID, assignee.Role, conversation.InboxID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Assignee is not a member of the conversation's inbox"})
+			return
+		}
+		previous := conversation.AssigneeID
+		updater.Update("assignee_id", assigneeID)
+
+		if h.notifications != nil && (previous == nil || *previous != assigneeID) {
+			actorID, _ := uuid.Parse(c.GetString("user_id"))
+			h.notifications.ConversationAssigned(&conversation, assigneeID, &actorID)
+		}
+	}
+
+	if h.wsHub != nil {
+		h.db.Preload("Contact").Preload("Inbox").First(&conversation, "id = ?", conversation.ID)
+		h.wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "conversation.updated", conversation)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "assigned"})
 }
 
 func (h *ConversationHandler) Resolve(c *gin.Context) {
-	id := c.Param("id")
-	h.db.Model(&models.Conversation{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":           "resolved",
+	var conversation models.Conversation
+	if !h.findConversation(c, &conversation) {
+		return
+	}
+
+	result := h.db.Model(&conversation).Where("status <> ?", statusResolved).Updates(map[string]interface{}{
+		"status":           statusResolved,
+		"snoozed_until":    nil,
 		"last_activity_at": time.Now(),
 	})
+	if result.RowsAffected > 0 {
+		h.recordStatusChange(c, &conversation, "Conversation was marked resolved by %s")
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "resolved"})
 }
 
 func (h *ConversationHandler) Reopen(c *gin.Context) {
-	id := c.Param("id")
-	h.db.Model(&models.Conversation{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":           "open",
+	var conversation models.Conversation
+	if !h.findConversation(c, &conversation) {
+		return
+	}
+	wasResolved := conversation.Status == statusResolved
+
+	result := h.db.Model(&conversation).Where("status <> ?", statusOpen).Updates(map[string]interface{}{
+		"status":           statusOpen,
+		"snoozed_until":    nil,
 		"last_activity_at": time.Now(),
 	})
+	if result.RowsAffected > 0 {
+		h.recordStatusChange(c, &conversation, "Conversation was reopened by %s")
+		if wasResolved && h.sla != nil {
+			h.sla.Restart(&conversation)
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "reopened"})
 }
 
+// Snooze a conversation until a given time (or until the contact writes again)
 func (h *ConversationHandler) Snooze(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "TODO"})
+	var conversation models.Conversation
+	if !h.findConversation(c, &conversation) {
+		return
+	}
+
+	var input struct {
+		SnoozedUntil *time.Time `json:"snoozed_until"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Without snoozed_until the conversation sleeps until the contact writes again
+	if input.SnoozedUntil != nil && !input.SnoozedUntil.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "snoozed_until must be in the future"})
+		return
+	}
+
+	if err := h.db.Model(&conversation).Updates(map[string]interface{}{
+		"status":        statusSnoozed,
+		"snoozed_until": input.SnoozedUntil,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.SnoozedUntil != nil {
+		h.recordStatusChange(c, &conversation, "Conversation was snoozed until "+input.SnoozedUntil.Format(time.RFC1123)+" by %s")
+	} else {
+		h.recordStatusChange(c, &conversation, "Conversation was snoozed by %s")
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "snoozed", "snoozed_until": input.SnoozedUntil})
 }
 
+// recordStatusChange records an activity message naming the agent who changed the status
+func (h *ConversationHandler) recordStatusChange(c *gin.Context, conversation *models.Conversation, format string) {
+	actor := c.GetString("email")
+	var user models.User
+	if err := h.db.Select("name").First(&user, "id = ?", c.GetString("user_id")).Error; err == nil && user.Name != "" {
+		actor = user.Name
+	}
+
+	recordActivity(h.db, h.wsHub, conversation, fmt.Sprintf(format, actor))
+}
+
+// AddLabel tags a conversation with a label of the account
 func (h *ConversationHandler) AddLabel(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "TODO"})
+	var conversation models.Conversation
+	if !h.findConversation(c, &conversation) {
+		return
+	}
+
+	var input struct {
+		LabelID string `json:"label_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	labelID, err := uuid.Parse(input.LabelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label_id"})
+		return
+	}
+
+	var label models.Label
+	if err := scoped(h.db, c).Where("id = ?", labelID).First(&label).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return
+	}
+
+	if err := h.db.Model(&conversation).Association("Labels").Append(&label); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add label"})
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
 }
 
+// RemoveLabel removes a label from a conversation
 func (h *ConversationHandler) RemoveLabel(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "TODO"})
+	var conversation models.Conversation
+	if !h.findConversation(c, &conversation) {
+		return
+	}
+
+	var label models.Label
+	if err := scoped(h.db, c).Where("id = ?", c.Param("label_id")).First(&label).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+		return
+	}
+
+	if err := h.db.Model(&conversation).Association("Labels").Delete(&label); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove label"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Label removed"})
 }
 
 func (h *ConversationHandler) ListByContact(c *gin.Context) {
 	contactID := c.Param("id")
 	var conversations []models.Conversation
-	h.db.Where("contact_id = ?", contactID).Find(&conversations)
+	if err := scoped(h.db, c).
+		Where("contact_id = ?", contactID).
+		Scopes(visibleInboxes(h.db, c)).
+		Order("last_activity_at desc").
+		Find(&conversations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, conversations)
 }
 
@@ -216,18 +478,31 @@ func (h *ConversationHandler) CreatePublicConversation(c *gin.Context) {
 // ---------------------------------------------------------------------
 
 type MessageHandler struct {
-	db    *gorm.DB
-	wsHub *websocket.Hub
+	db            *gorm.DB
+	wsHub         *websocket.Hub
+	cfg           *config.Config
+	channels      *channels.Registry
+	notifications *notifications.Service
 }
 
-func NewMessageHandler(db *gorm.DB, wsHub *websocket.Hub) *MessageHandler {
-	return &MessageHandler{db: db, wsHub: wsHub}
+func NewMessageHandler(db *gorm.DB, wsHub *websocket.Hub, cfg *config.Config, channelRegistry *channels.Registry, notifier *notifications.Service) *MessageHandler {
+	return &MessageHandler{db: db, wsHub: wsHub, cfg: cfg, channels: channelRegistry, notifications: notifier}
 }
 
+// deletedMessageContent replaces the content of deleted messages
+const deletedMessageContent = "This message was deleted"
+
 func (h *MessageHandler) ListByConversation(c *gin.Context) {
 	conversationID := c.Param("id")
+
+	var conversation models.Conversation
+	if err := scoped(h.db, c).Where("id = ?", conversationID).Scopes(visibleInboxes(h.db, c)).First(&conversation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
 	var messages []models.Message
-	if err := h.db.Preload("Attachments").Where("conversation_id = ?", conversationID).Order("created_at asc").Find(&messages).Error; err != nil {
+	if err := h.db.Preload("Attachments").Where("conversation_id = ?", conversation.ID).Order("created_at asc").Find(&messages).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -250,6 +525,7 @@ func (h *MessageHandler) Create(c *gin.Context) {
 		Content        string `json:"content"`
 		ContentType    string `json:"content_type"`
 		MessageType    string `json:"message_type"`
+		Private        bool   `json:"private"` // internal note, never sent to the contact
 		Attachments    []struct {
 			FileType string `json:"file_type"`
 			FileURL  string `json:"file_url"`
@@ -264,9 +540,9 @@ func (h *MessageHandler) Create(c *gin.Context) {
 
 	conversationUUID, _ := uuid.Parse(input.ConversationID)
 
-	// Validate conversation belongs to account
+	// Validate conversation belongs to account and to one of the user's inboxes
 	var conversation models.Conversation
-	if err := h.db.Where("id = ? AND account_id = ?", conversationUUID, accountID).First(&conversation).Error; err != nil {
+	if err := scoped(h.db, c).Preload("Inbox").Preload("Contact").Where("id = ?", conversationUUID).Scopes(visibleInboxes(h.db, c)).First(&conversation).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
 		return
 	}
@@ -275,15 +551,23 @@ func (h *MessageHandler) Create(c *gin.Context) {
 	message := models.Message{
 		ConversationID: conversation.ID,
 		SenderID:       &userID,
+		SenderType:     senderTypeUser,
 		Content:        input.Content,
 		ContentType:    input.ContentType,
 		MessageType:    "outgoing",
+		Private:        input.Private,
 		Status:         "sent",
 	}
 	if message.ContentType == "" {
 		message.ContentType = "text"
 	}
 
+	// Messages for the contact are delivered after the response; private notes stay internal
+	deliver := !message.Private && message.Content != ""
+	if deliver {
+		message.Status = "pending"
+	}
+
 	if err := h.db.Create(&message).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -309,27 +593,261 @@ func (h *MessageHandler) Create(c *gin.Context) {
 		"last_message":     input.Content, // Assuming we had this field, actually models doesn't show it but JSON response often simulates it
 	})
 
+	// First agent reply stops the SLA first-response clock; private notes are not replies
+	if !message.Private && conversation.FirstReplyCreatedAt == nil {
+		h.db.Model(&conversation).Update("first_reply_created_at", message.CreatedAt)
+	}
+
 	// Broadcast
 	if h.wsHub != nil {
-		h.wsHub.BroadcastToRoom(conversation.ID.String(), "message.created", message)
+		h.wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "message.created", message)
+	}
+	if h.cfg.EnableWebhooks {
+		dispatchWebhooks(h.db, conversation.AccountID, "message_created", &message)
+	}
+	if message.Private {
+		h.notifyMentions(&conversation, &message, userID)
+	}
+	if deliver {
+		go h.deliver(conversation, message)
 	}
-
-	// TODO: Trigger Outgoing Webhook to Evolution API here if needed
-	// This is where we would call SendText/SendMedia to the external provider
 
 	c.JSON(http.StatusCreated, message)
 }
 
+// deliver sends a pending message to the contact through the inbox channel, then
+// records the outcome and broadcasts it as message.updated
+func (h *MessageHandler) deliver(conversation models.Conversation, message models.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	status := "sent"
+	sourceID, err := h.channels.Deliver(ctx, &conversation.Inbox, &conversation.Contact, &message)
+	if err != nil && !errors.Is(err, channels.ErrNoProvider) {
+		log.Printf("Failed to deliver message to conversation %s: %v", conversation.ID, err)
+		status = "failed"
+	}
+
+	if err := h.db.Model(&models.Message{}).Where("id = ?", message.ID).
+		Updates(map[string]interface{}{"status": status, "source_id": sourceID}).Error; err != nil {
+		log.Printf("Failed to record delivery of message %s: %v", message.ID, err)
+		return
+	}
+
+	// The agent may have deleted the message while it was being sent; then the
+	// contact must not keep it either
+	var stored models.Message
+	if err := h.db.Preload("Attachments").First(&stored, "id = ?", message.ID).Error; err != nil {
+		return
+	}
+	if stored.Deleted() && sourceID != "" {
+		if err := h.channels.Revoke(ctx, &conversation.Inbox, &conversation.Contact, sourceID); err != nil && !errors.Is(err, channels.ErrNoProvider) {
+			log.Printf("Failed to delete message %s on the channel: %v", message.ID, err)
+		}
+	}
+	if h.wsHub != nil {
+		h.wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "message.updated", stored)
+	}
+}
+
+// find loads the message of the request, with its conversation, if the user can see it
+func (h *MessageHandler) find(c *gin.Context) (*models.Message, *models.Conversation, bool) {
+	var message models.Message
+	if err := h.db.Preload("Attachments").First(&message, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return nil, nil, false
+	}
+	var conversation models.Conversation
+	if err := scoped(h.db, c).Where("id = ?", message.ConversationID).Scopes(visibleInboxes(h.db, c)).First(&conversation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return nil, nil, false
+	}
+	return &message, &conversation, true
+}
+
+// Get returns a message with its earlier versions
 func (h *MessageHandler) Get(c *gin.Context) {
-	c.JSON(200, gin.H{"message": "TODO"})
+	message, _, ok := h.find(c)
+	if !ok {
+		return
+	}
+	h.db.Where("message_id = ?", message.ID).Order("created_at asc").Find(&message.Revisions)
+	c.JSON(http.StatusOK, message)
 }
 
+// Update edits the content of the agent's own outgoing message within the edit window.
+// The previous content is kept as a revision and, when the channel supports it, the
+// message is edited for the contact too.
 func (h *MessageHandler) Update(c *gin.Context) {
-	c.JSON(200, gin.H{"message": "TODO"})
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+
+	var input struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(input.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content cannot be empty"})
+		return
+	}
+
+	message, conversation, ok := h.find(c)
+	if !ok {
+		return
+	}
+	if message.SenderType != senderTypeUser || message.SenderID == nil || *message.SenderID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own messages"})
+		return
+	}
+	// A pending message is still being delivered with its current content
+	if message.MessageType != "outgoing" || message.Deleted() || message.Status == "pending" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "This message cannot be edited"})
+		return
+	}
+	if window := h.cfg.MessageEditWindow; window > 0 && time.Since(message.CreatedAt) > window {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Messages can only be edited within " + window.String() + " of being sent"})
+		return
+	}
+	if input.Content == message.Content {
+		c.JSON(http.StatusOK, message)
+		return
+	}
+
+	previous := message.Content
+	now := time.Now()
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		revision := models.MessageRevision{MessageID: message.ID, Content: previous, EditedByID: &userID}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		return tx.Model(message).Updates(map[string]interface{}{"content": input.Content, "edited_at": now}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	message.Content = input.Content
+	message.EditedAt = &now
+
+	if !message.Private && message.SourceID != "" {
+		h.propagate(conversation, message.ID, "edit", func(ctx context.Context, inbox *models.Inbox, contact *models.Contact) error {
+			return h.channels.EditText(ctx, inbox, contact, message.SourceID, input.Content)
+		})
+	}
+	if h.wsHub != nil {
+		h.wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "message.updated", message)
+	}
+	c.JSON(http.StatusOK, message)
 }
 
+// Delete replaces a message with a tombstone, dropping its content, earlier versions and
+// attachments. Agents can delete their own messages and administrators any message.
+// Outgoing messages are deleted for the contact too when the channel supports it.
 func (h *MessageHandler) Delete(c *gin.Context) {
-	c.JSON(200, gin.H{"message": "TODO"})
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+
+	message, conversation, ok := h.find(c)
+	if !ok {
+		return
+	}
+	own := message.SenderType == senderTypeUser && message.SenderID != nil && *message.SenderID == userID
+	if !own && !isAdministrator(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own messages"})
+		return
+	}
+	if message.MessageType == "activity" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "This message cannot be deleted"})
+		return
+	}
+	if message.Deleted() {
+		c.JSON(http.StatusOK, message)
+		return
+	}
+
+	attributes := models.JSONB{}
+	for key, value := range message.ContentAttributes {
+		attributes[key] = value
+	}
+	attributes["deleted"] = true
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("message_id = ?", message.ID).Delete(&models.MessageRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", message.ID).Delete(&models.Attachment{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(message).Updates(map[string]interface{}{
+			"content":            deletedMessageContent,
+			"content_attributes": attributes,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	message.Content = deletedMessageContent
+	message.ContentAttributes = attributes
+	message.Attachments = nil
+
+	if message.MessageType == "outgoing" && !message.Private && message.SourceID != "" {
+		sourceID := message.SourceID
+		h.propagate(conversation, message.ID, "delete", func(ctx context.Context, inbox *models.Inbox, contact *models.Contact) error {
+			return h.channels.Revoke(ctx, inbox, contact, sourceID)
+		})
+	}
+	if h.wsHub != nil {
+		h.wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "message.deleted", message)
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// notifyMentions notifies the agents mentioned in a private note who can see the conversation
+func (h *MessageHandler) notifyMentions(conversation *models.Conversation, message *models.Message, authorID uuid.UUID) {
+	if h.notifications == nil {
+		return
+	}
+	for _, member := range notifications.Mentions(h.db, conversation.AccountID, message.Content) {
+		if !canAccessConversation(h.db, member.UserID, conversation.AccountID, member.Role, conversation.ID) {
+			continue
+		}
+		err := h.notifications.Notify(&models.Notification{
+			AccountID:        conversation.AccountID,
+			UserID:           member.UserID,
+			NotificationType: notifications.TypeMention,
+			ActorID:          &authorID,
+			ConversationID:   &conversation.ID,
+			MessageID:        &message.ID,
+		})
+		if err != nil {
+			log.Printf("Failed to notify mention of user %s: %v", member.UserID, err)
+		}
+	}
+}
+
+// propagate applies an edit or deletion to the message the contact received, in the
+// background. Channels that cannot do it keep the original message.
+func (h *MessageHandler) propagate(conversation *models.Conversation, messageID uuid.UUID, action string, apply func(ctx context.Context, inbox *models.Inbox, contact *models.Contact) error) {
+	if h.channels == nil {
+		return
+	}
+	go func() {
+		var inbox models.Inbox
+		var contact models.Contact
+		if err := h.db.First(&inbox, "id = ?", conversation.InboxID).Error; err != nil {
+			return
+		}
+		if err := h.db.First(&contact, "id = ?", conversation.ContactID).Error; err != nil {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := apply(ctx, &inbox, &contact); err != nil && !errors.Is(err, channels.ErrNoProvider) {
+			log.Printf("Failed to %s message %s on the channel: %v", action, messageID, err)
+		}
+	}()
 }
 
 func (h *MessageHandler) CreatePublicMessage(c *gin.Context) {
@@ -362,7 +880,7 @@ func (h *ContactHandler) List(c *gin.Context) {
 	limit := 15
 	offset := (page - 1) * limit
 
-	query := h.db.Model(&models.Contact{}).Where("account_id = ?", accountID)
+	query := scoped(h.db, c).Model(&models.Contact{})
 
 	if search != "" {
 		searchLike := "%" + search + "%"
@@ -416,7 +934,7 @@ func (h *ContactHandler) Create(c *gin.Context) {
 func (h *ContactHandler) Get(c *gin.Context) {
 	id := c.Param("id")
 	var contact models.Contact
-	if err := h.db.First(&contact, "id = ?", id).Error; err != nil {
+	if err := scoped(h.db, c).First(&contact, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
 		return
 	}
@@ -425,11 +943,10 @@ func (h *ContactHandler) Get(c *gin.Context) {
 
 func (h *ContactHandler) Update(c *gin.Context) {
 	id := c.Param("id")
-	accountID := c.GetString("account_id")
 
 	// Verificar se contato existe e pertence à conta
 	var contact models.Contact
-	if err := h.db.Where("id = ? AND account_id = ?", id, accountID).First(&contact).Error; err != nil {
+	if err := scoped(h.db, c).Where("id = ?", id).First(&contact).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
 		return
 	}
@@ -465,18 +982,17 @@ func (h *ContactHandler) Update(c *gin.Context) {
 	}
 
 	// Recarregar contato atualizado
-	h.db.First(&contact, id)
+	h.db.First(&contact, "id = ?", contact.ID)
 
 	c.JSON(http.StatusOK, contact)
 }
 
 func (h *ContactHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
-	accountID := c.GetString("account_id")
 
 	// Verificar se contato existe e pertence à conta
 	var contact models.Contact
-	if err := h.db.Where("id = ? AND account_id = ?", id, accountID).First(&contact).Error; err != nil {
+	if err := scoped(h.db, c).Where("id = ?", id).First(&contact).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
 		return
 	}
@@ -508,9 +1024,8 @@ func NewInboxHandler(db *gorm.DB) *InboxHandler {
 }
 
 func (h *InboxHandler) List(c *gin.Context) {
-	accountID := c.GetString("account_id")
 	var inboxes []models.Inbox
-	if err := h.db.Where("account_id = ?", accountID).Find(&inboxes).Error; err != nil {
+	if err := scoped(h.db, c).Scopes(visibleInboxIDs(h.db, c)).Find(&inboxes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -544,17 +1059,83 @@ func (h *InboxHandler) Create(c *gin.Context) {
 func (h *InboxHandler) Get(c *gin.Context) {
 	id := c.Param("id")
 	var inbox models.Inbox
-	if err := h.db.First(&inbox, "id = ?", id).Error; err != nil {
+	if err := scoped(h.db, c).Scopes(visibleInboxIDs(h.db, c)).First(&inbox, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Inbox not found"})
 		return
 	}
 	c.JSON(http.StatusOK, inbox)
 }
 
+// Update changes the settings of an inbox. Working hours have their own endpoint.
 func (h *InboxHandler) Update(c *gin.Context) {
-	c.JSON(200, gin.H{"message": "TODO"})
+	inbox, ok := h.findAccountInbox(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name                       *string `json:"name"`
+		AvatarURL                  *string `json:"avatar_url"`
+		EnableAutoAssignment       *bool   `json:"enable_auto_assignment"`
+		GreetingEnabled            *bool   `json:"greeting_enabled"`
+		GreetingMessage            *string `json:"greeting_message"`
+		AllowMessagesAfterResolved *bool   `json:"allow_messages_after_resolved"`
+		ReopenWindowHours          *int    `json:"reopen_window_hours" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		if *input.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+			return
+		}
+		updates["name"] = *input.Name
+	}
+	if input.AvatarURL != nil {
+		updates["avatar_url"] = *input.AvatarURL
+	}
+	if input.EnableAutoAssignment != nil {
+		updates["enable_auto_assignment"] = *input.EnableAutoAssignment
+	}
+	if input.GreetingEnabled != nil {
+		updates["greeting_enabled"] = *input.GreetingEnabled
+	}
+	if input.GreetingMessage != nil {
+		updates["greeting_message"] = *input.GreetingMessage
+	}
+	if input.AllowMessagesAfterResolved != nil {
+		updates["allow_messages_after_resolved"] = *input.AllowMessagesAfterResolved
+	}
+	if input.ReopenWindowHours != nil {
+		updates["reopen_window_hours"] = *input.ReopenWindowHours
+	}
+
+	if len(updates) > 0 {
+		if err := h.db.Model(inbox).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	h.db.First(inbox, "id = ?", inbox.ID)
+	c.JSON(http.StatusOK, inbox)
 }
 
+// Delete removes an inbox of the account
 func (h *InboxHandler) Delete(c *gin.Context) {
-	c.JSON(200, gin.H{"message": "TODO"})
+	inbox, ok := h.findAccountInbox(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(inbox).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Inbox deleted"})
 }
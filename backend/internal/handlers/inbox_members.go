@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// findAccountInbox loads an inbox by the :id path param, scoped to the current account
+func (h *InboxHandler) findAccountInbox(c *gin.Context) (*models.Inbox, bool) {
+	var inbox models.Inbox
+	if err := scoped(h.db, c).Where("id = ?", c.Param("id")).First(&inbox).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inbox not found"})
+		return nil, false
+	}
+	return &inbox, true
+}
+
+// findVisibleInbox is findAccountInbox restricted to the inboxes the user is a member
+// of (administrators see all)
+func (h *InboxHandler) findVisibleInbox(c *gin.Context) (*models.Inbox, bool) {
+	inbox, ok := h.findAccountInbox(c)
+	if !ok {
+		return nil, false
+	}
+	if !canAccessInbox(h.db, c, inbox.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inbox not found"})
+		return nil, false
+	}
+	return inbox, true
+}
+
+// ListMembers lists the agents that can see an inbox. Agents only see the members of
+// the inboxes they belong to.
+func (h *InboxHandler) ListMembers(c *gin.Context) {
+	inbox, ok := h.findVisibleInbox(c)
+	if !ok {
+		return
+	}
+
+	var users []models.User
+	if err := h.db.
+		Joins("JOIN inbox_members ON inbox_members.user_id = users.id").
+		Where("inbox_members.inbox_id = ?", inbox.ID).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range users {
+		users[i].PasswordHash = ""
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// AddMembers adds agents of the account to an inbox
+func (h *InboxHandler) AddMembers(c *gin.Context) {
+	inbox, ok := h.findAccountInbox(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		UserIDs []string `json:"user_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(input.UserIDs))
+	seen := make(map[uuid.UUID]bool, len(input.UserIDs))
+	for _, raw := range input.UserIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID: " + raw})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+
+	// Only users that belong to the inbox's account can become members
+	var count int64
+	h.db.Model(&models.AccountUser{}).
+		Where("account_id = ? AND user_id IN ?", inbox.AccountID, userIDs).
+		Count(&count)
+	if int(count) != len(userIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "All users must belong to the account"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for _, userID := range userIDs {
+			member := models.InboxMember{InboxID: inbox.ID, UserID: userID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add inbox members"})
+		return
+	}
+
+	h.ListMembers(c)
+}
+
+// RemoveMember removes an agent from an inbox
+func (h *InboxHandler) RemoveMember(c *gin.Context) {
+	inbox, ok := h.findAccountInbox(c)
+	if !ok {
+		return
+	}
+
+	result := h.db.Where("inbox_id = ? AND user_id = ?", inbox.ID, c.Param("user_id")).Delete(&models.InboxMember{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
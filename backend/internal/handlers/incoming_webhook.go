@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -10,18 +11,31 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/channels"
+	"github.com/nakamura/chatwoot-go/internal/middleware"
 	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/notifications"
+	"github.com/nakamura/chatwoot-go/internal/sla"
 	"github.com/nakamura/chatwoot-go/internal/websocket"
 	"gorm.io/gorm"
 )
 
 type IncomingWebhookHandler struct {
-	db    *gorm.DB
-	wsHub *websocket.Hub
+	db            *gorm.DB
+	wsHub         *websocket.Hub
+	sla           *sla.Service
+	notifications *notifications.Service
+	autoReplier   *autoReplier
 }
 
-func NewIncomingWebhookHandler(db *gorm.DB, wsHub *websocket.Hub) *IncomingWebhookHandler {
-	return &IncomingWebhookHandler{db: db, wsHub: wsHub}
+func NewIncomingWebhookHandler(db *gorm.DB, wsHub *websocket.Hub, slaService *sla.Service, channelRegistry *channels.Registry, notifier *notifications.Service) *IncomingWebhookHandler {
+	return &IncomingWebhookHandler{
+		db:            db,
+		wsHub:         wsHub,
+		sla:           slaService,
+		notifications: notifier,
+		autoReplier:   &autoReplier{db: db, wsHub: wsHub, channels: channelRegistry},
+	}
 }
 
 // HandleIncoming processes incoming webhooks from external services
@@ -42,17 +56,24 @@ func (h *IncomingWebhookHandler) HandleIncoming(c *gin.Context) {
 	}
 
 	// Validate token
-	var accessToken models.AccessToken
-	if err := h.db.Where("token = ?", apiToken).Preload("User").First(&accessToken).Error; err != nil {
+	accessToken, err := middleware.FindAccessToken(h.db, apiToken)
+	if errors.Is(err, middleware.ErrTokenExpired) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API token expired"})
+		return
+	}
+	if err != nil || accessToken.AccountID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
 		return
 	}
-
-	// Check expiration
-	if accessToken.ExpiresAt != nil && accessToken.ExpiresAt.Before(time.Now()) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "API token expired"})
+	if !accessToken.HasScope(models.ScopeWebhooksIngest) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API token is missing the " + models.ScopeWebhooksIngest + " scope", "code": "insufficient_scope"})
+		return
+	}
+	if _, err := middleware.AccountRole(h.db, accessToken.OwnerID, *accessToken.AccountID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User does not have access to the token's account"})
 		return
 	}
+	middleware.TouchAccessToken(h.db, accessToken)
 
 	// Parse incoming payload
 	body, err := io.ReadAll(c.Request.Body)
@@ -82,11 +103,11 @@ func (h *IncomingWebhookHandler) HandleIncoming(c *gin.Context) {
 	// Process based on event type
 	switch eventType {
 	case "message", "messages.upsert":
-		h.handleMessageEvent(c, accessToken, payload)
+		h.handleMessageEvent(c, *accessToken, payload)
 	case "message_delivered", "message_read":
-		h.handleStatusEvent(c, accessToken, payload)
+		h.handleStatusEvent(c, *accessToken, payload)
 	case "connection", "qrcode":
-		h.handleConnectionEvent(c, accessToken, payload)
+		h.handleConnectionEvent(c, *accessToken, payload)
 	default:
 		// Store as generic event for processing
 		log.Printf("Unhandled event type: %s", eventType)
@@ -105,6 +126,7 @@ func (h *IncomingWebhookHandler) handleMessageEvent(c *gin.Context, token models
 	var content string
 	var messageType string = "text"
 	var attachments []map[string]interface{}
+	var fromMe bool
 
 	// Evolution-Go format
 	if data, ok := payload["data"].(map[string]interface{}); ok {
@@ -112,6 +134,7 @@ func (h *IncomingWebhookHandler) handleMessageEvent(c *gin.Context, token models
 			if remoteJid, ok := key["remoteJid"].(string); ok {
 				phoneNumber = extractPhoneFromJid(remoteJid)
 			}
+			fromMe, _ = key["fromMe"].(bool)
 		}
 		if message, ok := data["message"].(map[string]interface{}); ok {
 			if conv, ok := message["conversation"].(string); ok {
@@ -189,6 +212,12 @@ func (h *IncomingWebhookHandler) handleMessageEvent(c *gin.Context, token models
 		return
 	}
 
+	// Messages sent from the instance itself (our replies echoed back) are not contact activity
+	if fromMe {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored", "reason": "from_me"})
+		return
+	}
+
 	// Identify Inbox and Account ID from URL path (wildcard)
 	pathParam := c.Param("pathParam")
 	pathParam = strings.TrimPrefix(pathParam, "/")
@@ -221,49 +250,18 @@ func (h *IncomingWebhookHandler) handleMessageEvent(c *gin.Context, token models
 
 	// Find or Create Inbox
 	var inbox models.Inbox
-	// ... User fetch logic follows ...
-
-	// Determine Account ID
-	var accountID uuid.UUID
-	// accountIDParam já foi extraído acima
-
-	var user models.User
-	if err := h.db.Preload("Accounts").First(&user, token.OwnerID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
-		return
-	}
-
+	// The token is bound to an account; an account in the URL must be that one
+	accountID := *token.AccountID
 	if accountIDParam != "" {
-		// Use explicit account ID from URL
-		// First validate format
 		parsedID, err := uuid.Parse(accountIDParam)
 		if err != nil {
-			// If not a UUID, maybe it's a numeric ID (legacy Chatwoot)?
-			// But our models use UUID. Assuming UUID for now as per Go implementation.
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Account ID format (must be UUID)"})
 			return
 		}
-
-		// Verify user belongs to this account
-		hasAccess := false
-		for _, acc := range user.Accounts {
-			if acc.ID == parsedID {
-				hasAccess = true
-				break
-			}
-		}
-		if !hasAccess {
-			c.JSON(http.StatusForbidden, gin.H{"error": "User does not have access to the specified account"})
+		if parsedID != accountID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API token does not belong to the specified account"})
 			return
 		}
-		accountID = parsedID
-	} else {
-		// Fallback: Use user's first account
-		if len(user.Accounts) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User has no accounts"})
-			return
-		}
-		accountID = user.Accounts[0].ID
 	}
 
 	if err := h.db.Where("account_id = ? AND name = ?", accountID, instanceName).First(&inbox).Error; err != nil {
@@ -297,27 +295,19 @@ func (h *IncomingWebhookHandler) handleMessageEvent(c *gin.Context, token models
 		}
 	}
 
-	// Find or create conversation
-	var conversation models.Conversation
-	if err := h.db.Where("inbox_id = ? AND contact_id = ? AND status = ?", inbox.ID, contact.ID, "open").First(&conversation).Error; err != nil {
-		// Create new conversation
-		conversation = models.Conversation{
-			InboxID:        inbox.ID,
-			ContactID:      contact.ID,
-			AccountID:      accountID,
-			Status:         "open",
-			LastActivityAt: time.Now(),
-		}
-		if err := h.db.Create(&conversation).Error; err != nil {
-			log.Printf("Failed to create conversation: %v", err)
-			return
-		}
+	// Find, reopen or create conversation
+	conversation, isNewConversation, err := h.conversationForIncoming(&inbox, &contact)
+	if err != nil {
+		log.Printf("Failed to create conversation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create conversation"})
+		return
 	}
 
 	// Create message
 	message := models.Message{
 		ConversationID: conversation.ID,
 		ContactID:      &contact.ID,
+		SenderType:     senderTypeContact,
 		Content:        content,
 		ContentType:    messageType,
 		MessageType:    "incoming",
@@ -327,20 +317,29 @@ func (h *IncomingWebhookHandler) handleMessageEvent(c *gin.Context, token models
 
 	// Update conversation
 	conversation.LastActivityAt = time.Now()
-	h.db.Save(&conversation)
+	h.db.Model(&conversation).Update("last_activity_at", conversation.LastActivityAt)
+
+	// Greet new conversations, then reply automatically when the inbox is outside its working hours
+	go h.autoReplier.replyTo(inbox, contact, conversation, isNewConversation)
+
+	if isNewConversation && h.notifications != nil {
+		h.notifications.ConversationCreated(&conversation, nil)
+	}
 
 	// Broadcast via WebSocket
 	if h.wsHub != nil {
 		// Broadcast to conversation room (for users viewing this conversation)
 		h.wsHub.BroadcastToRoom(
-			conversation.ID.String(),
+			conversation.AccountID,
+			websocket.ConversationRoom(conversation.ID),
 			"message.created",
 			message,
 		)
 
-		// Broadcast to global notifications channel (for notification badges)
+		// Broadcast to the inbox's notifications (for notification badges)
 		h.wsHub.BroadcastToRoom(
-			"notifications",
+			conversation.AccountID,
+			websocket.InboxRoom(inbox.ID),
 			"message.created",
 			map[string]interface{}{
 				"conversation_id": conversation.ID,
@@ -385,74 +384,3 @@ func extractPhoneFromJid(jid string) string {
 	}
 	return jid
 }
-
-// GenerateToken creates a new API access token for a user
-func (h *IncomingWebhookHandler) GenerateToken(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	var input struct {
-		Name string `json:"name"`
-	}
-	c.ShouldBindJSON(&input)
-
-	if input.Name == "" {
-		input.Name = "API Token"
-	}
-
-	// UserID from middleware is string
-	userUUIDStr := userID.(string)
-	userUUID, err := uuid.Parse(userUUIDStr)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
-		return
-	}
-	accessToken := models.AccessToken{
-		OwnerID:   userUUID,
-		OwnerType: "User",
-		Name:      input.Name,
-	}
-
-	if err := h.db.Create(&accessToken).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, accessToken)
-}
-
-// ListTokens lists all API tokens for the current user
-func (h *IncomingWebhookHandler) ListTokens(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	var tokens []models.AccessToken
-	h.db.Where("owner_id = ? AND owner_type = ?", userID, "User").Find(&tokens)
-
-	c.JSON(http.StatusOK, tokens)
-}
-
-// DeleteToken deletes an API token
-func (h *IncomingWebhookHandler) DeleteToken(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	tokenID := c.Param("id")
-
-	result := h.db.Where("id = ? AND owner_id = ?", tokenID, userID).Delete(&models.AccessToken{})
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Token deleted"})
-}
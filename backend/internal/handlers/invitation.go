@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/config"
+	"github.com/nakamura/chatwoot-go/internal/mailer"
+	"github.com/nakamura/chatwoot-go/internal/middleware"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/security"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// invitationTTL is how long an invitation link stays valid
+const invitationTTL = 7 * 24 * time.Hour
+
+type InvitationHandler struct {
+	db     *gorm.DB
+	cfg    *config.Config
+	mailer mailer.Mailer
+}
+
+func NewInvitationHandler(db *gorm.DB, cfg *config.Config, m mailer.Mailer) *InvitationHandler {
+	return &InvitationHandler{db: db, cfg: cfg, mailer: m}
+}
+
+// List lists the pending invitations of the account (admin only)
+func (h *InvitationHandler) List(c *gin.Context) {
+	var invitations []models.Invitation
+	if err := scoped(h.db, c).
+		Where("accepted_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at desc").
+		Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// Create invites someone by email to join the account (admin only)
+func (h *InvitationHandler) Create(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+		Role  string `json:"role" binding:"omitempty,oneof=administrator agent supervisor"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Role == "" {
+		input.Role = "agent"
+	}
+	email := normalizeEmail(input.Email)
+
+	accountID, _ := uuid.Parse(c.GetString("account_id"))
+	inviterID, _ := uuid.Parse(c.GetString("user_id"))
+
+	var members int64
+	h.db.Model(&models.AccountUser{}).
+		Joins("JOIN users ON users.id = account_users.user_id").
+		Where("account_users.account_id = ? AND LOWER(users.email) = ?", accountID, email).
+		Count(&members)
+	if members > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member of this account"})
+		return
+	}
+
+	var pending int64
+	scoped(h.db, c).Model(&models.Invitation{}).
+		Where("email = ? AND accepted_at IS NULL AND expires_at > ?", email, time.Now()).
+		Count(&pending)
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An invitation is already pending for this email"})
+		return
+	}
+
+	token, err := security.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token"})
+		return
+	}
+
+	invitation := models.Invitation{
+		AccountID:   accountID,
+		Email:       email,
+		Role:        input.Role,
+		TokenHash:   security.HashToken(token),
+		InvitedByID: &inviterID,
+		ExpiresAt:   time.Now().Add(invitationTTL),
+	}
+	if err := h.db.Create(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	h.sendInvitation(&invitation, token)
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// Resend issues a new token for a pending invitation and emails it again (admin only).
+// The previous link stops working.
+func (h *InvitationHandler) Resend(c *gin.Context) {
+	var invitation models.Invitation
+	if err := scoped(h.db, c).Where("id = ? AND accepted_at IS NULL", c.Param("id")).First(&invitation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	token, err := security.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invitation token"})
+		return
+	}
+
+	invitation.TokenHash = security.HashToken(token)
+	invitation.ExpiresAt = time.Now().Add(invitationTTL)
+	if err := h.db.Model(&invitation).Updates(map[string]interface{}{
+		"token_hash": invitation.TokenHash,
+		"expires_at": invitation.ExpiresAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invitation"})
+		return
+	}
+
+	h.sendInvitation(&invitation, token)
+
+	c.JSON(http.StatusOK, invitation)
+}
+
+// Delete revokes a pending invitation (admin only)
+func (h *InvitationHandler) Delete(c *gin.Context) {
+	result := scoped(h.db, c).Where("id = ? AND accepted_at IS NULL", c.Param("id")).Delete(&models.Invitation{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// Show describes the invitation behind a token, so the invitee knows whether to
+// create a password or sign in with their existing one (public)
+func (h *InvitationHandler) Show(c *gin.Context) {
+	invitation, ok := h.findPending(c, c.Query("token"))
+	if !ok {
+		return
+	}
+
+	var existing int64
+	h.db.Model(&models.User{}).Where("LOWER(email) = ?", invitation.Email).Count(&existing)
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":         invitation.Email,
+		"role":          invitation.Role,
+		"account_name":  invitation.Account.Name,
+		"expires_at":    invitation.ExpiresAt,
+		"existing_user": existing > 0,
+	})
+}
+
+// Accept joins the invited account. New users choose their name and password;
+// existing users confirm with their current password (public).
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Name     string `json:"name"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, ok := h.findPending(c, input.Token)
+	if !ok {
+		return
+	}
+
+	// Accounts with single sign-on provision their users on first sign-in instead
+	if passwordLoginDisabled(h.db, invitation.AccountID) {
+		respondSSORequired(c, invitation.AccountID)
+		return
+	}
+
+	var user models.User
+	existing := h.db.Where("LOWER(email) = ?", invitation.Email).First(&user).Error == nil
+	if existing {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+	} else {
+		if input.Name == "" || len(input.Password) < 8 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name and a password of at least 8 characters are required"})
+			return
+		}
+	}
+
+	errAlreadyAccepted := errors.New("invitation already accepted")
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Single use: only the first accept can flip accepted_at
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.ID).
+			Update("accepted_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyAccepted
+		}
+
+		if !existing {
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			user = models.User{
+				Name:         input.Name,
+				Email:        invitation.Email,
+				PasswordHash: string(hashedPassword),
+				DisplayName:  input.Name,
+				Role:         invitation.Role,
+				Availability: "online",
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		}
+
+		accountUser := models.AccountUser{AccountID: invitation.AccountID, UserID: user.ID, Role: invitation.Role}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&accountUser).Error
+	})
+	if errors.Is(err, errAlreadyAccepted) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invitation has already been used"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+		return
+	}
+
+	role, err := middleware.AccountRole(h.db, user.ID, invitation.AccountID)
+	if err != nil {
+		role = invitation.Role
+	}
+	// Existing users with two-factor authentication still have to pass it
+	if user.TwoFactorEnabled {
+		respondWithChallenge(c, h.cfg, &user, invitation.AccountID, authMethodPassword)
+		return
+	}
+
+	response, err := startSession(h.db, h.cfg, c, &user, invitation.AccountID, role, authMethodPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// findPending loads the invitation of a token, answering 404 for unknown tokens and
+// 410 for used or expired ones
+func (h *InvitationHandler) findPending(c *gin.Context, token string) (*models.Invitation, bool) {
+	var invitation models.Invitation
+	if token == "" || h.db.Preload("Account").Where("token_hash = ?", security.HashToken(token)).First(&invitation).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return nil, false
+	}
+
+	if invitation.AcceptedAt != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Invitation has already been used"})
+		return nil, false
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invitation has expired"})
+		return nil, false
+	}
+
+	return &invitation, true
+}
+
+// sendInvitation emails the invitation link. Failures are logged; the admin can resend.
+func (h *InvitationHandler) sendInvitation(invitation *models.Invitation, token string) {
+	var account models.Account
+	h.db.Select("name").First(&account, "id = ?", invitation.AccountID)
+
+	link := fmt.Sprintf("%s/accept-invitation?token=%s", strings.TrimRight(h.cfg.FrontendURL, "/"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You have been invited to %s", account.Name),
+		Text: fmt.Sprintf(
+			"You have been invited to join %s as %s.\n\nAccept the invitation:\n%s\n\nThis link expires on %s.\n",
+			account.Name, invitation.Role, link, invitation.ExpiresAt.UTC().Format("2006-01-02 15:04 UTC"),
+		),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Printf("Failed to send invitation %s: %v", invitation.ID, err)
+	}
+}
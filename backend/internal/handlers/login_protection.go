@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/mailer"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/security"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Brute-force protection of password logins
+const (
+	loginIPLimit       = 30 // attempts per IP per loginLimitWindow
+	loginEmailLimit    = 10 // attempts per email per loginLimitWindow
+	loginLimitWindow   = 15 * time.Minute
+	loginDelayAfter    = 2 // failures before delays start
+	maxLoginDelay      = 30 * time.Second
+	maxFailedLogins    = 5
+	lockoutDuration    = 30 * time.Minute
+	unlockTokenTTL     = 24 * time.Hour
+	loginThrottleError = "Too many login attempts, try again later"
+)
+
+// loginDelay returns how long a user has to wait after their last failure: it doubles
+// with each failure past loginDelayAfter (2s, 4s, 8s, ...), up to maxLoginDelay
+func loginDelay(failures int) time.Duration {
+	if failures < loginDelayAfter {
+		return 0
+	}
+	delay := time.Second << uint(failures-loginDelayAfter+1)
+	if delay > maxLoginDelay || delay <= 0 {
+		return maxLoginDelay
+	}
+	return delay
+}
+
+// normalizeEmail is the form emails are compared and stored in
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// throttleLogin applies the per IP and per email limits. It answers and returns false
+// when the attempt is refused.
+func (h *AuthHandler) throttleLogin(c *gin.Context, email string) bool {
+	email = normalizeEmail(email)
+	if !h.allow(c, "login:ip:"+c.ClientIP(), loginIPLimit, loginLimitWindow) ||
+		!h.allow(c, "login:email:"+email, loginEmailLimit, loginLimitWindow) {
+		c.Header("Retry-After", fmt.Sprint(int(loginLimitWindow.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": loginThrottleError})
+		return false
+	}
+	return true
+}
+
+// loginFailures is the failed sign-in state of an email: of its user, or of the
+// LoginFailure row of an unknown email
+type loginFailures struct {
+	Attempts     int
+	LastFailedAt *time.Time
+	LockedUntil  *time.Time
+}
+
+// checkLockout refuses logins of locked emails and of emails still in their progressive delay
+func (h *AuthHandler) checkLockout(c *gin.Context, failures loginFailures) bool {
+	now := time.Now()
+	if failures.LockedUntil != nil && now.Before(*failures.LockedUntil) {
+		c.JSON(http.StatusLocked, gin.H{
+			"error":        "Account locked after too many failed logins. Check your email to unlock it or try again later.",
+			"locked_until": failures.LockedUntil,
+		})
+		return false
+	}
+
+	if failures.LastFailedAt != nil {
+		if wait := failures.LastFailedAt.Add(loginDelay(failures.Attempts)).Sub(now); wait > 0 {
+			c.Header("Retry-After", fmt.Sprint(int(wait.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": loginThrottleError})
+			return false
+		}
+	}
+	return true
+}
+
+// registerFailedLogin counts a failed password and locks the user after maxFailedLogins
+func (h *AuthHandler) registerFailedLogin(c *gin.Context, user *models.User) {
+	now := time.Now()
+	if err := h.db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_attempts": gorm.Expr("failed_login_attempts + 1"),
+		"last_failed_login_at":  now,
+	}).Error; err != nil {
+		log.Printf("Failed to record failed login for user %s: %v", user.ID, err)
+		return
+	}
+
+	h.db.Select("failed_login_attempts").First(user, "id = ?", user.ID)
+	if user.FailedLoginAttempts < maxFailedLogins {
+		return
+	}
+
+	lockedUntil := now.Add(lockoutDuration)
+	if err := h.db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"locked_until":          lockedUntil,
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+	}).Error; err != nil {
+		log.Printf("Failed to lock user %s: %v", user.ID, err)
+		return
+	}
+
+	recordAudit(h.db, c, auditUserLocked, &user.ID, nil, models.JSONB{
+		"failed_attempts": user.FailedLoginAttempts,
+		"locked_until":    lockedUntil,
+	})
+	// In the background, so that the response time does not tell a lockout apart
+	go h.sendUnlockEmail(user.ID, lockedUntil)
+}
+
+// unknownEmailFailures loads the failed sign-in state of an email without a user
+func (h *AuthHandler) unknownEmailFailures(email string) loginFailures {
+	var failure models.LoginFailure
+	h.db.Where("email = ?", email).Limit(1).Find(&failure)
+	return loginFailures{
+		Attempts:     failure.FailedLoginAttempts,
+		LastFailedAt: failure.LastFailedLoginAt,
+		LockedUntil:  failure.LockedUntil,
+	}
+}
+
+// registerUnknownEmailFailure counts a failed sign-in of an email without a user and
+// locks it after maxFailedLogins, like registerFailedLogin does for users
+func (h *AuthHandler) registerUnknownEmailFailure(email string) {
+	now := time.Now()
+	failures := h.unknownEmailFailures(email)
+
+	failure := models.LoginFailure{
+		Email:               email,
+		FailedLoginAttempts: failures.Attempts + 1,
+		LastFailedLoginAt:   &now,
+		LockedUntil:         failures.LockedUntil,
+	}
+	if failure.FailedLoginAttempts >= maxFailedLogins {
+		lockedUntil := now.Add(lockoutDuration)
+		failure.FailedLoginAttempts, failure.LastFailedLoginAt, failure.LockedUntil = 0, nil, &lockedUntil
+	}
+	if err := h.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&failure).Error; err != nil {
+		log.Printf("Failed to record failed login for unknown email: %v", err)
+	}
+
+	// Forget emails nobody tried for a day
+	h.db.Where("updated_at < ?", now.Add(-24*time.Hour)).Delete(&models.LoginFailure{})
+}
+
+// rejectUnknownPassword spends the time of a password check on a sign-in with an
+// unknown email, so that response times do not reveal it
+func rejectUnknownPassword(password string) {
+	bcrypt.CompareHashAndPassword(unknownUserHash(), []byte(password))
+}
+
+var unknownUserHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
+	return hash
+})
+
+// clearFailedLogins resets the failure counter after a successful login
+func (h *AuthHandler) clearFailedLogins(user *models.User) {
+	if user.FailedLoginAttempts == 0 && user.LastFailedLoginAt == nil && user.LockedUntil == nil {
+		return
+	}
+	h.db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	})
+}
+
+// sendUnlockEmail emails a link that lifts the lockout right away
+func (h *AuthHandler) sendUnlockEmail(userID uuid.UUID, lockedUntil time.Time) {
+	token, err := security.NewToken()
+	if err != nil {
+		log.Printf("Failed to generate unlock token for user %s: %v", userID, err)
+		return
+	}
+
+	unlockToken := models.UnlockToken{
+		UserID:    userID,
+		TokenHash: security.HashToken(token),
+		ExpiresAt: time.Now().Add(unlockTokenTTL),
+	}
+	if err := h.db.Create(&unlockToken).Error; err != nil {
+		log.Printf("Failed to create unlock token for user %s: %v", userID, err)
+		return
+	}
+
+	var recipient models.User
+	if err := h.db.Select("id", "name", "email").First(&recipient, "id = ?", userID).Error; err != nil {
+		return
+	}
+
+	link := fmt.Sprintf("%s/unlock?token=%s", strings.TrimRight(h.cfg.FrontendURL, "/"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      recipient.Email,
+		Subject: "Your account has been locked",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nYour account was locked after %d failed sign-in attempts. It unlocks automatically at %s.\n\nIf it was you, unlock it now:\n%s\n\nIf it was not you, consider resetting your password.\n",
+			recipient.Name, maxFailedLogins, lockedUntil.UTC().Format("2006-01-02 15:04 UTC"), link,
+		),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		log.Printf("Failed to send unlock email to user %s: %v", userID, err)
+	}
+}
+
+// Unlock lifts a lockout with the token emailed to the user
+func (h *AuthHandler) Unlock(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var unlockToken models.UnlockToken
+	if err := h.db.Where("token_hash = ?", security.HashToken(req.Token)).First(&unlockToken).Error; err != nil ||
+		unlockToken.UsedAt != nil || time.Now().After(unlockToken.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired unlock token"})
+		return
+	}
+
+	result := h.db.Model(&models.UnlockToken{}).
+		Where("id = ? AND used_at IS NULL", unlockToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired unlock token"})
+		return
+	}
+
+	if err := h.db.Model(&models.User{}).Where("id = ?", unlockToken.UserID).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+
+	recordAudit(h.db, c, auditUserUnlocked, &unlockToken.UserID, nil, models.JSONB{"method": "email"})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked, you can sign in again"})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/notifications"
+	"gorm.io/gorm"
+)
+
+type NotificationHandler struct {
+	db            *gorm.DB
+	notifications *notifications.Service
+}
+
+func NewNotificationHandler(db *gorm.DB, notifier *notifications.Service) *NotificationHandler {
+	return &NotificationHandler{db: db, notifications: notifier}
+}
+
+// mine starts a query on the notifications of the current user in the active account
+func (h *NotificationHandler) mine(c *gin.Context) *gorm.DB {
+	return scoped(h.db, c).Model(&models.Notification{}).Where("user_id = ?", c.GetString("user_id"))
+}
+
+// List lists the notifications of the current user, newest first. ?status=unread
+// lists only the unread ones.
+func (h *NotificationHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit := 25
+
+	query := h.mine(c)
+	if c.Query("status") == "unread" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var count, unreadCount int64
+	if err := query.Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.mine(c).Where("read_at IS NULL").Count(&unreadCount)
+
+	var list []models.Notification
+	if err := query.Preload("Actor").Preload("Conversation").
+		Order("created_at desc").Limit(limit).Offset((page - 1) * limit).
+		Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"meta": gin.H{
+			"count":        count,
+			"unread_count": unreadCount,
+			"current_page": page,
+		},
+		"payload": list,
+	})
+}
+
+// MarkRead marks a notification of the current user as read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	var notification models.Notification
+	if err := h.mine(c).Where("id = ?", c.Param("id")).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := h.db.Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		notification.ReadAt = &now
+	}
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllRead marks every unread notification of the current user as read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	result := h.mine(c).Where("read_at IS NULL").Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
+
+// GetSettings returns the notification preferences of the current user in the active account
+func (h *NotificationHandler) GetSettings(c *gin.Context) {
+	accountID, _ := uuid.Parse(c.GetString("account_id"))
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	c.JSON(http.StatusOK, h.notifications.Preferences(accountID, userID))
+}
+
+// UpdateSettings changes the notification preferences of the current user in the active
+// account, in-app and by email. Types left out keep their current setting.
+func (h *NotificationHandler) UpdateSettings(c *gin.Context) {
+	var input notifications.Preferences
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accountID, _ := uuid.Parse(c.GetString("account_id"))
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	preferences := h.notifications.Preferences(accountID, userID)
+	for notificationType, enabled := range input.InApp {
+		if _, ok := preferences.InApp[notificationType]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type: " + notificationType, "types": notifications.Types})
+			return
+		}
+		preferences.InApp[notificationType] = enabled
+	}
+	for emailType, enabled := range input.Email {
+		if _, ok := preferences.Email[emailType]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown email type: " + emailType})
+			return
+		}
+		preferences.Email[emailType] = enabled
+	}
+
+	if err := h.notifications.SetPreferences(accountID, userID, preferences); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preferences)
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/config"
+	"github.com/nakamura/chatwoot-go/internal/middleware"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/security"
+	"gorm.io/gorm"
+)
+
+// Token lifetimes
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// How a session was opened (Session.AuthMethod)
+const (
+	authMethodPassword = "password"
+	authMethodSSO      = "sso"
+)
+
+// startSession opens a session for a user in an account and issues its access and refresh tokens
+func startSession(db *gorm.DB, cfg *config.Config, c *gin.Context, user *models.User, accountID uuid.UUID, role, authMethod string) (*LoginResponse, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:     user.ID,
+		AccountID:  accountID,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+		AuthMethod: authMethod,
+	}
+	if authMethod == authMethodSSO {
+		session.SSOAccountID = &accountID
+	}
+
+	var refreshToken string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		var err error
+		refreshToken, err = issueRefreshToken(tx, &session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := signToken(cfg, user, accountID, role, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.PasswordHash = ""
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		User:         user,
+	}, nil
+}
+
+// issueRefreshToken stores a new refresh token for a session and returns it
+func issueRefreshToken(tx *gorm.DB, session *models.Session) (string, error) {
+	token, err := security.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: security.HashToken(token),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := tx.Create(&refreshToken).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// signToken signs a short-lived access token for a session of a user in one of their accounts
+func signToken(cfg *config.Config, user *models.User, accountID uuid.UUID, role string, sessionID uuid.UUID) (string, error) {
+	now := time.Now()
+	claims := middleware.Claims{
+		UserID:       user.ID,
+		Email:        user.Email,
+		Role:         role,
+		AccountID:    accountID,
+		SessionID:    sessionID,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(cfg.JWTSecret))
+}
+
+// revokeSessions revokes the active sessions matched by a query on sessions
+func revokeSessions(query *gorm.DB) error {
+	return query.Model(&models.Session{}).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+// invalidateUserTokens bumps the token version of a user and revokes all their sessions
+func invalidateUserTokens(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+	return revokeSessions(tx.Where("user_id = ?", userID))
+}
+
+// Refresh rotates a refresh token and issues a new access token. Presenting an already
+// rotated refresh token revokes the session, since it means the token was stolen.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.allow(c, "refresh:ip:"+c.ClientIP(), 60, time.Minute) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+		return
+	}
+
+	invalid := gin.H{"error": "Invalid or expired refresh token"}
+
+	var refreshToken models.RefreshToken
+	if err := h.db.Where("token_hash = ?", security.HashToken(req.RefreshToken)).First(&refreshToken).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, invalid)
+		return
+	}
+
+	var session models.Session
+	if err := h.db.First(&session, "id = ?", refreshToken.SessionID).Error; err != nil ||
+		session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, invalid)
+		return
+	}
+
+	errReused := errors.New("refresh token reused")
+	var newRefreshToken string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL", refreshToken.ID).
+			Update("rotated_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errReused
+		}
+
+		if err := tx.Model(&session).Update("last_used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var err error
+		newRefreshToken, err = issueRefreshToken(tx, &session)
+		return err
+	})
+	if errors.Is(err, errReused) {
+		revokeSessions(h.db.Where("id = ?", session.ID))
+		h.wsHub.DisconnectSession(session.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", session.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, invalid)
+		return
+	}
+	role, err := middleware.AccountRole(h.db, user.ID, session.AccountID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this account"})
+		return
+	}
+
+	token, err := signToken(h.cfg, &user, session.AccountID, role, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	user.PasswordHash = ""
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:        token,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		User:         &user,
+	})
+}
+
+// Logout revokes the current session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if err := revokeSessions(h.db.Where("id = ?", c.GetString("session_id"))); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
+		return
+	}
+	sessionID, _ := uuid.Parse(c.GetString("session_id"))
+	h.wsHub.DisconnectSession(sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out"})
+}
+
+// ListSessions lists the active sessions of the current user
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	var sessions []models.Session
+	if err := h.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", c.GetString("user_id"), time.Now()).
+		Order("last_used_at desc").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type sessionResponse struct {
+		models.Session
+		Current bool `json:"current"`
+	}
+	currentID := c.GetString("session_id")
+	result := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, sessionResponse{Session: session, Current: session.ID.String() == currentID})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RevokeSession signs out one of the current user's sessions
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	result := h.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), c.GetString("user_id")).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	sessionID, _ := uuid.Parse(c.Param("id"))
+	h.wsHub.DisconnectSession(sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions signs out every session of the current user except this one
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	var sessionIDs []uuid.UUID
+	h.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", c.GetString("user_id"), c.GetString("session_id")).
+		Pluck("id", &sessionIDs)
+	if err := revokeSessions(h.db.Where("user_id = ? AND id <> ?", c.GetString("user_id"), c.GetString("session_id"))); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	for _, sessionID := range sessionIDs {
+		h.wsHub.DisconnectSession(sessionID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked"})
+}
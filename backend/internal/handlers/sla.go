@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/sla"
+	"gorm.io/gorm"
+)
+
+type SLAHandler struct {
+	db *gorm.DB
+}
+
+func NewSLAHandler(db *gorm.DB) *SLAHandler {
+	return &SLAHandler{db: db}
+}
+
+type slaPolicyInput struct {
+	Name                 string  `json:"name"`
+	Description          *string `json:"description"`
+	InboxID              *string `json:"inbox_id"`
+	Priority             *string `json:"priority"`
+	FirstResponseMinutes *int    `json:"first_response_minutes" binding:"omitempty,min=0"`
+	ResolutionMinutes    *int    `json:"resolution_minutes" binding:"omitempty,min=0"`
+	WarningMinutes       *int    `json:"warning_minutes" binding:"omitempty,min=0"`
+	BusinessHoursOnly    *bool   `json:"business_hours_only"`
+	Active               *bool   `json:"active"`
+}
+
+// List SLA policies of the account
+func (h *SLAHandler) List(c *gin.Context) {
+	var policies []models.SLAPolicy
+	if err := scoped(h.db, c).Order("created_at asc").Find(&policies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+// Create an SLA policy
+func (h *SLAHandler) Create(c *gin.Context) {
+	accountID, _ := uuid.Parse(c.GetString("account_id"))
+
+	var input slaPolicyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	policy := models.SLAPolicy{
+		AccountID:         accountID,
+		Name:              input.Name,
+		WarningMinutes:    15,
+		BusinessHoursOnly: true,
+		Active:            true,
+	}
+	if !h.applyInput(c, &policy, &input) {
+		return
+	}
+
+	if err := h.db.Create(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// Update an SLA policy. Deadlines of existing conversations are kept.
+func (h *SLAHandler) Update(c *gin.Context) {
+	var policy models.SLAPolicy
+	if err := scoped(h.db, c).Where("id = ?", c.Param("id")).First(&policy).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLA policy not found"})
+		return
+	}
+
+	var input slaPolicyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Name != "" {
+		policy.Name = input.Name
+	}
+	if !h.applyInput(c, &policy, &input) {
+		return
+	}
+
+	if err := h.db.Save(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// Delete an SLA policy
+func (h *SLAHandler) Delete(c *gin.Context) {
+	result := scoped(h.db, c).Where("id = ?", c.Param("id")).Delete(&models.SLAPolicy{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLA policy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SLA policy deleted"})
+}
+
+// applyInput copies the optional fields of the request into the policy
+func (h *SLAHandler) applyInput(c *gin.Context, policy *models.SLAPolicy, input *slaPolicyInput) bool {
+	if input.Description != nil {
+		policy.Description = *input.Description
+	}
+	if input.InboxID != nil {
+		if *input.InboxID == "" {
+			policy.InboxID = nil
+		} else {
+			var inbox models.Inbox
+			if err := scoped(h.db, c).Where("id = ?", *input.InboxID).First(&inbox).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Inbox not found"})
+				return false
+			}
+			policy.InboxID = &inbox.ID
+		}
+	}
+	if input.Priority != nil {
+		if *input.Priority != "" && !validPriority(*input.Priority) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid priority: " + *input.Priority})
+			return false
+		}
+		policy.Priority = *input.Priority
+	}
+	if input.FirstResponseMinutes != nil {
+		policy.FirstResponseMinutes = *input.FirstResponseMinutes
+	}
+	if input.ResolutionMinutes != nil {
+		policy.ResolutionMinutes = *input.ResolutionMinutes
+	}
+	if input.WarningMinutes != nil {
+		policy.WarningMinutes = *input.WarningMinutes
+	}
+	if input.BusinessHoursOnly != nil {
+		policy.BusinessHoursOnly = *input.BusinessHoursOnly
+	}
+	if input.Active != nil {
+		policy.Active = *input.Active
+	}
+	return true
+}
+
+// Report returns SLA compliance per policy for conversations created in a period, in
+// the inboxes the user can see. Query params: since, until (RFC3339, default last 30
+// days), inbox_id.
+func (h *SLAHandler) Report(c *gin.Context) {
+	until := time.Now()
+	since := until.AddDate(0, 0, -30)
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since (RFC3339 expected)"})
+			return
+		}
+		since = t
+	}
+	if v := c.Query("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid until (RFC3339 expected)"})
+			return
+		}
+		until = t
+	}
+
+	// breaches joins the conversations that breached a metric ("" = any metric)
+	breaches := func(alias, metric string) string {
+		sub := "SELECT DISTINCT conversation_id FROM sla_events WHERE event_type = '" + sla.EventBreached + "'"
+		if metric != "" {
+			sub += " AND metric = '" + metric + "'"
+		}
+		return "LEFT JOIN (" + sub + ") " + alias + " ON " + alias + ".conversation_id = conversations.id"
+	}
+
+	query := scoped(h.db, c).Model(&models.Conversation{}).
+		Select("sla_policies.id AS sla_policy_id, sla_policies.name AS name, "+
+			"COUNT(conversations.id) AS total_conversations, "+
+			"COUNT(fr.conversation_id) AS first_response_breaches, "+
+			"COUNT(res.conversation_id) AS resolution_breaches, "+
+			"COUNT(anyb.conversation_id) AS breached_conversations").
+		Joins("JOIN sla_policies ON sla_policies.id = conversations.sla_policy_id").
+		Joins(breaches("fr", sla.MetricFirstResponse)).
+		Joins(breaches("res", sla.MetricResolution)).
+		Joins(breaches("anyb", "")).
+		Where("conversations.created_at BETWEEN ? AND ?", since, until).
+		Group("sla_policies.id, sla_policies.name")
+
+	// Agents only count the conversations of their inboxes
+	if !isAdministrator(c) {
+		query = query.Where("conversations.inbox_id IN (?)", memberInboxIDs(h.db, c.GetString("user_id")))
+	}
+	if v := c.Query("inbox_id"); v != "" {
+		inboxID, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inbox_id"})
+			return
+		}
+		query = query.Where("conversations.inbox_id = ?", inboxID)
+	}
+
+	type policyReport struct {
+		SLAPolicyID           uuid.UUID `json:"sla_policy_id"`
+		Name                  string    `json:"name"`
+		TotalConversations    int64     `json:"total_conversations"`
+		FirstResponseBreaches int64     `json:"first_response_breaches"`
+		ResolutionBreaches    int64     `json:"resolution_breaches"`
+		BreachedConversations int64     `json:"breached_conversations"`
+		ComplianceRate        float64   `json:"compliance_rate" gorm:"-"` // share of conversations without any breach
+	}
+
+	var reports []policyReport
+	if err := query.Scan(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total, breached int64
+	for i := range reports {
+		reports[i].ComplianceRate = complianceRate(reports[i].TotalConversations, reports[i].BreachedConversations)
+		total += reports[i].TotalConversations
+		breached += reports[i].BreachedConversations
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"since":           since,
+		"until":           until,
+		"compliance_rate": complianceRate(total, breached),
+		"policies":        reports,
+	})
+}
+
+func complianceRate(total, breached int64) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(total-breached) / float64(total)
+}
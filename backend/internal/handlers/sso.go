@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/config"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/oidc"
+	"github.com/nakamura/chatwoot-go/internal/security"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ssoLoginTTL is how long a user has to complete the sign-in at the identity provider
+const ssoLoginTTL = 10 * time.Minute
+
+// errSSONotMember is returned when an identity provider asserts the email of a user
+// who has not joined its account
+var errSSONotMember = errors.New("user is not a member of the account")
+
+type SSOHandler struct {
+	db   *gorm.DB
+	cfg  *config.Config
+	oidc *oidc.Client
+}
+
+func NewSSOHandler(db *gorm.DB, cfg *config.Config) *SSOHandler {
+	return &SSOHandler{db: db, cfg: cfg, oidc: oidc.NewClient(cfg.OIDCAllowInsecureIssuers)}
+}
+
+// ssoProviderResponse is the provider configuration as shown to administrators
+type ssoProviderResponse struct {
+	*models.OIDCProvider
+	HasClientSecret bool   `json:"has_client_secret"`
+	RedirectURI     string `json:"redirect_uri"`
+}
+
+// redirectURI is where the identity provider sends users back: the SPA, which
+// forwards the code to POST /auth/sso/callback
+func (h *SSOHandler) redirectURI() string {
+	return strings.TrimSuffix(h.cfg.FrontendURL, "/") + "/sso/callback"
+}
+
+func (h *SSOHandler) oidcConfig(provider *models.OIDCProvider) oidc.Config {
+	return oidc.Config{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURI:  h.redirectURI(),
+	}
+}
+
+// passwordLoginDisabled reports whether an account only accepts single sign-on
+func passwordLoginDisabled(db *gorm.DB, accountID uuid.UUID) bool {
+	var count int64
+	db.Model(&models.Account{}).Where("id = ? AND password_login_disabled", accountID).Count(&count)
+	return count > 0
+}
+
+// respondSSORequired rejects a password sign-in to an account that requires single sign-on
+func respondSSORequired(c *gin.Context, accountID uuid.UUID) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":      "This account requires single sign-on",
+		"code":       "sso_required",
+		"account_id": accountID,
+	})
+}
+
+// GetProvider returns the OIDC configuration of the account (admin only)
+func (h *SSOHandler) GetProvider(c *gin.Context) {
+	var provider models.OIDCProvider
+	if err := scoped(h.db, c).First(&provider).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured", "redirect_uri": h.redirectURI()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ssoProviderResponse{
+		OIDCProvider:    &provider,
+		HasClientSecret: provider.ClientSecret != "",
+		RedirectURI:     h.redirectURI(),
+	})
+}
+
+// UpdateProvider creates or replaces the OIDC configuration of the account (admin only).
+// The issuer must answer discovery before the configuration is saved.
+func (h *SSOHandler) UpdateProvider(c *gin.Context) {
+	var input struct {
+		Issuer         string            `json:"issuer" binding:"required,url"`
+		ClientID       string            `json:"client_id" binding:"required"`
+		ClientSecret   *string           `json:"client_secret"` // omit to keep the current secret
+		AllowedDomains []string          `json:"allowed_domains"`
+		RoleClaim      string            `json:"role_claim"`
+		RoleMapping    map[string]string `json:"role_mapping" binding:"omitempty,dive,keys,required,endkeys,oneof=administrator agent supervisor"`
+		DefaultRole    string            `json:"default_role" binding:"omitempty,oneof=administrator agent supervisor"`
+		Enabled        *bool             `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accountID, err := uuid.Parse(c.GetString("account_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid account ID in context"})
+		return
+	}
+
+	domains := make([]string, 0, len(input.AllowedDomains))
+	for _, domain := range input.AllowedDomains {
+		if domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	// Sign-in by email picks the provider by domain, so a domain belongs to one account
+	for _, domain := range domains {
+		var claimed int64
+		h.db.Model(&models.OIDCProvider{}).
+			Where("account_id <> ? AND (' ' || allowed_domains || ' ') LIKE ?", accountID, "% "+domain+" %").
+			Count(&claimed)
+		if claimed > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "The domain " + domain + " is already used for single sign-on by another account"})
+			return
+		}
+	}
+
+	if _, err := h.oidc.Discover(c.Request.Context(), input.Issuer); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Could not reach the identity provider: " + err.Error()})
+		return
+	}
+
+	var provider models.OIDCProvider
+	if err := scoped(h.db, c).First(&provider).Error; err != nil {
+		provider = models.OIDCProvider{AccountID: accountID, Enabled: true}
+	}
+	mapping := models.JSONB{}
+	for value, role := range input.RoleMapping {
+		mapping[value] = role
+	}
+
+	provider.Issuer = strings.TrimSuffix(input.Issuer, "/")
+	provider.ClientID = input.ClientID
+	if input.ClientSecret != nil {
+		provider.ClientSecret = *input.ClientSecret
+	}
+	provider.AllowedDomains = strings.Join(domains, " ")
+	provider.RoleClaim = input.RoleClaim
+	provider.RoleMapping = mapping
+	provider.DefaultRole = input.DefaultRole
+	if provider.DefaultRole == "" {
+		provider.DefaultRole = "agent"
+	}
+	if input.Enabled != nil {
+		provider.Enabled = *input.Enabled
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&provider).Error; err != nil {
+			return err
+		}
+		// Never leave an account without a way to sign in
+		if !provider.Enabled {
+			return tx.Model(&models.Account{}).Where("id = ?", accountID).Update("password_login_disabled", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save single sign-on configuration"})
+		return
+	}
+
+	h.GetProvider(c)
+}
+
+// DeleteProvider removes the OIDC configuration of the account and re-enables
+// password login (admin only)
+func (h *SSOHandler) DeleteProvider(c *gin.Context) {
+	accountID := c.GetString("account_id")
+	var deleted int64
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("account_id = ?", accountID).Delete(&models.OIDCProvider{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return tx.Model(&models.Account{}).Where("id = ?", accountID).Update("password_login_disabled", false).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Single sign-on removed"})
+}
+
+// Start begins a single sign-on. The provider is chosen by account_id, or by the
+// domain of email among the providers that restrict domains. It answers with the
+// URL of the identity provider to redirect the browser to.
+func (h *SSOHandler) Start(c *gin.Context) {
+	var input struct {
+		AccountID string `json:"account_id" binding:"omitempty,uuid"`
+		Email     string `json:"email" binding:"omitempty,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := h.db.Where("enabled")
+	switch {
+	case input.AccountID != "":
+		query = query.Where("account_id = ?", input.AccountID)
+	case input.Email != "":
+		domain := emailDomain(input.Email)
+		query = query.Where("(' ' || allowed_domains || ' ') LIKE ?", "% "+domain+" %")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account_id or email is required"})
+		return
+	}
+
+	var provider models.OIDCProvider
+	if err := query.Order("created_at asc").First(&provider).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No single sign-on provider found"})
+		return
+	}
+
+	metadata, err := h.oidc.Discover(c.Request.Context(), provider.Issuer)
+	if err != nil {
+		log.Printf("SSO discovery failed for account %s: %v", provider.AccountID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	state, err := security.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	nonce, err := security.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+
+	request := models.OIDCLoginRequest{
+		AccountID:    provider.AccountID,
+		StateHash:    security.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(ssoLoginTTL),
+	}
+	if err := h.db.Create(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": oidc.AuthCodeURL(metadata, h.oidcConfig(&provider), state, nonce, verifier),
+	})
+}
+
+// Callback completes a single sign-on with the code and state returned by the
+// identity provider. Users are provisioned into the account on first sign-in.
+func (h *SSOHandler) Callback(c *gin.Context) {
+	var input struct {
+		Code  string `json:"code" binding:"required"`
+		State string `json:"state" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Single use: only the first callback can claim the request
+	var request models.OIDCLoginRequest
+	if err := h.db.Where("state_hash = ? AND used_at IS NULL AND expires_at > ?", security.HashToken(input.State), time.Now()).
+		First(&request).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in, please try again"})
+		return
+	}
+	result := h.db.Model(&models.OIDCLoginRequest{}).
+		Where("id = ? AND used_at IS NULL", request.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in, please try again"})
+		return
+	}
+
+	var provider models.OIDCProvider
+	if err := h.db.Where("account_id = ? AND enabled", request.AccountID).First(&provider).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Single sign-on is not enabled for this account"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	metadata, err := h.oidc.Discover(ctx, provider.Issuer)
+	if err != nil {
+		log.Printf("SSO discovery failed for account %s: %v", provider.AccountID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+	rawIDToken, err := h.oidc.Exchange(ctx, metadata, h.oidcConfig(&provider), input.Code, request.CodeVerifier)
+	if err != nil {
+		log.Printf("SSO code exchange failed for account %s: %v", provider.AccountID, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in was rejected by the identity provider"})
+		return
+	}
+	claims, err := h.oidc.Verify(ctx, metadata, provider.ClientID, rawIDToken, request.Nonce)
+	if err != nil {
+		log.Printf("SSO ID token rejected for account %s: %v", provider.AccountID, err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid identity token"})
+		return
+	}
+
+	email := strings.ToLower(claims.String("email"))
+	if email == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The identity provider did not share an email address"})
+		return
+	}
+	if verified, ok := claims.Bool("email_verified"); ok && !verified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The email address is not verified by the identity provider"})
+		return
+	}
+	if !domainAllowed(provider.AllowedDomains, email) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This email domain is not allowed for the account"})
+		return
+	}
+
+	user, accountUser, err := h.provision(c, &provider, claims, email)
+	if errors.Is(err, errSSONotMember) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This email already has a user; ask an administrator of the account for an invitation"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to provision user"})
+		return
+	}
+
+	// The identity provider replaces the password, not the second factor
+	if user.TwoFactorEnabled {
+		respondWithChallenge(c, h.cfg, user, accountUser.AccountID, authMethodSSO)
+		return
+	}
+
+	response, err := startSession(h.db, h.cfg, c, user, accountUser.AccountID, accountUser.Role, authMethodSSO)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// provision finds or creates the user of a verified identity and its membership in
+// the provider's account, applying the role mapping. An existing user is only signed
+// in if they already belong to the account: an identity provider cannot claim users
+// of other accounts (errSSONotMember).
+func (h *SSOHandler) provision(c *gin.Context, provider *models.OIDCProvider, claims oidc.Claims, email string) (*models.User, *models.AccountUser, error) {
+	role, mapped := mapRole(provider, claims)
+
+	var user models.User
+	var accountUser models.AccountUser
+	var created bool
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			// SSO users get an unusable password; they can set one with a password reset
+			secret, err := security.NewToken()
+			if err != nil {
+				return err
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+
+			name := claims.String("name")
+			if name == "" {
+				name = claims.String("preferred_username")
+			}
+			if name == "" {
+				name = strings.SplitN(email, "@", 2)[0]
+			}
+			user = models.User{
+				Name:         name,
+				Email:        email,
+				PasswordHash: string(hash),
+				DisplayName:  name,
+				Role:         role,
+				Availability: "online",
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			created = true
+		}
+
+		err := tx.Where("account_id = ? AND user_id = ?", provider.AccountID, user.ID).First(&accountUser).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if !created {
+				return errSSONotMember
+			}
+			accountUser = models.AccountUser{AccountID: provider.AccountID, UserID: user.ID, Role: role}
+			return tx.Create(&accountUser).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		// Keep the role in sync with the identity provider, without removing the last administrator
+		if mapped && accountUser.Role != role && !isLastAdministrator(tx, &accountUser) {
+			accountUser.Role = role
+			return tx.Model(&models.AccountUser{}).
+				Where("account_id = ? AND user_id = ?", accountUser.AccountID, accountUser.UserID).
+				Update("role", role).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if created {
+		recordAudit(h.db, c, auditUserProvisioned, &user.ID, &provider.AccountID, models.JSONB{"method": authMethodSSO})
+	}
+	return &user, &accountUser, nil
+}
+
+// mapRole returns the account role for the claims and whether the role mapping
+// matched. The highest mapped role wins; without a match the default role applies.
+func mapRole(provider *models.OIDCProvider, claims oidc.Claims) (string, bool) {
+	rank := map[string]int{"agent": 1, "supervisor": 2, roleAdministrator: 3}
+
+	best := ""
+	if provider.RoleClaim != "" {
+		for _, value := range claims.Strings(provider.RoleClaim) {
+			if role, ok := provider.RoleMapping[value].(string); ok && rank[role] > rank[best] {
+				best = role
+			}
+		}
+	}
+	if best != "" {
+		return best, true
+	}
+
+	if provider.DefaultRole != "" {
+		return provider.DefaultRole, false
+	}
+	return "agent", false
+}
+
+// domainAllowed checks an email against a space separated list of domains; an empty
+// list allows every domain
+func domainAllowed(allowedDomains, email string) bool {
+	domains := strings.Fields(allowedDomains)
+	if len(domains) == 0 {
+		return true
+	}
+	domain := emailDomain(email)
+	for _, d := range domains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
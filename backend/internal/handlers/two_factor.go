@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/config"
+	"github.com/nakamura/chatwoot-go/internal/middleware"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/security"
+	"github.com/nakamura/chatwoot-go/internal/totp"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorIssuer       = "Chatwoot"
+	twoFactorChallengeTTL = 5 * time.Minute
+	// twoFactorAudience keeps challenge tokens from being accepted as access tokens
+	twoFactorAudience  = "2fa_challenge"
+	recoveryCodesCount = 10
+)
+
+// twoFactorChallengeClaims is the token returned by a password login when the user has
+// two-factor authentication enabled; it is exchanged for a session by VerifyTwoFactor
+type twoFactorChallengeClaims struct {
+	UserID     uuid.UUID `json:"user_id"`
+	AccountID  uuid.UUID `json:"account_id"`
+	AuthMethod string    `json:"amr"` // first factor, carried to the session
+	jwt.RegisteredClaims
+}
+
+// respondWithChallenge answers a successful first factor (password or SSO) with a two-factor challenge
+func respondWithChallenge(c *gin.Context, cfg *config.Config, user *models.User, accountID uuid.UUID, authMethod string) {
+	now := time.Now()
+	claims := twoFactorChallengeClaims{
+		UserID:     user.ID,
+		AccountID:  accountID,
+		AuthMethod: authMethod,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{twoFactorAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(twoFactorChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSecret))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"two_factor_required": true,
+		"challenge_token":     token,
+		"expires_in":          int(twoFactorChallengeTTL.Seconds()),
+	})
+}
+
+// VerifyTwoFactor completes a login with a TOTP code or a recovery code
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	claims := &twoFactorChallengeClaims{}
+	token, err := jwt.ParseWithClaims(req.ChallengeToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(h.cfg.JWTSecret), nil
+	}, jwt.WithAudience(twoFactorAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, sign in again"})
+		return
+	}
+
+	if !h.allow(c, "2fa:user:"+claims.UserID.String(), 5, 5*time.Minute) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, try again later"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", claims.UserID).Error; err != nil || !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge, sign in again"})
+		return
+	}
+
+	var valid bool
+	if req.Code != "" {
+		valid = acceptTOTP(h.db, &user, req.Code)
+	} else {
+		valid = useRecoveryCode(h.db, user.ID, req.RecoveryCode)
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	role, err := middleware.AccountRole(h.db, user.ID, claims.AccountID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No access to this account"})
+		return
+	}
+
+	authMethod := claims.AuthMethod
+	if authMethod == "" {
+		authMethod = authMethodPassword
+	}
+	response, err := startSession(h.db, h.cfg, c, &user, claims.AccountID, role, authMethod)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SetupTwoFactor generates a new TOTP secret for the current user. Two-factor
+// authentication is enabled once a code from it is confirmed with EnableTwoFactor.
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := h.db.Model(&user).Update("two_factor_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(twoFactorIssuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor confirms the secret from SetupTwoFactor with a code and returns the
+// recovery codes, which are shown only once
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TwoFactorSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start the two-factor setup first"})
+		return
+	}
+
+	step, ok := totp.Validate(user.TwoFactorSecret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	var codes []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"two_factor_enabled":   true,
+			"two_factor_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off after re-confirming the password
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	user, ok := h.confirmPassword(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"two_factor_enabled":   false,
+			"two_factor_secret":    "",
+			"two_factor_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after re-confirming the password
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := h.confirmPassword(c)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	var codes []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// confirmPassword loads the current user and checks the password of the request body
+func (h *AuthHandler) confirmPassword(c *gin.Context) (*models.User, bool) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// acceptTOTP validates a TOTP code of a user, refusing a step that was already used
+func acceptTOTP(db *gorm.DB, user *models.User, code string) bool {
+	step, ok := totp.Validate(user.TwoFactorSecret, code, time.Now())
+	if !ok || step <= user.TwoFactorLastStep {
+		return false
+	}
+
+	// Conditional update so two concurrent requests cannot both use the step
+	result := db.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+		Update("two_factor_last_step", step)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// useRecoveryCode consumes an unused recovery code of a user
+func useRecoveryCode(db *gorm.DB, userID uuid.UUID, code string) bool {
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, security.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and generates new ones
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(b)
+		code := raw[:5] + "-" + raw[5:]
+
+		recoveryCode := models.RecoveryCode{UserID: userID, CodeHash: security.HashToken(normalizeRecoveryCode(code))}
+		if err := tx.Create(&recoveryCode).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in recovery codes
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
@@ -29,7 +29,7 @@ func (h *WebhookHandler) List(c *gin.Context) {
 	}
 
 	var webhooks []models.Webhook
-	if err := h.db.Where("account_id = ?", accountID).Find(&webhooks).Error; err != nil {
+	if err := scoped(h.db, c).Find(&webhooks).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -46,10 +46,11 @@ func (h *WebhookHandler) Create(c *gin.Context) {
 	}
 
 	var input struct {
-		Name          string   `json:"name"`
-		URL           string   `json:"url" binding:"required"`
-		InboxID       string   `json:"inbox_id"`
-		Subscriptions []string `json:"subscriptions"`
+		Name           string   `json:"name"`
+		URL            string   `json:"url" binding:"required"`
+		InboxID        string   `json:"inbox_id"`
+		Subscriptions  []string `json:"subscriptions"`
+		IncludePrivate bool     `json:"include_private"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -60,16 +61,21 @@ func (h *WebhookHandler) Create(c *gin.Context) {
 	accUUID, _ := uuid.Parse(accountID)
 
 	webhook := models.Webhook{
-		AccountID:     accUUID,
-		Name:          input.Name,
-		URL:           input.URL,
-		Subscriptions: input.Subscriptions,
-		WebhookType:   "account",
+		AccountID:      accUUID,
+		Name:           input.Name,
+		URL:            input.URL,
+		Subscriptions:  input.Subscriptions,
+		WebhookType:    "account",
+		IncludePrivate: input.IncludePrivate,
 	}
 
 	if input.InboxID != "" {
-		inboxUUID, _ := uuid.Parse(input.InboxID)
-		webhook.InboxID = &inboxUUID
+		var inbox models.Inbox
+		if err := scoped(h.db, c).First(&inbox, "id = ?", input.InboxID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Inbox not found"})
+			return
+		}
+		webhook.InboxID = &inbox.ID
 		webhook.WebhookType = "inbox"
 	}
 
@@ -83,19 +89,17 @@ func (h *WebhookHandler) Create(c *gin.Context) {
 
 // Update a webhook
 func (h *WebhookHandler) Update(c *gin.Context) {
-	id := c.Param("id")
-	accountID := c.GetString("account_id")
-
 	var webhook models.Webhook
-	if err := h.db.Where("id = ? AND account_id = ?", id, accountID).First(&webhook).Error; err != nil {
+	if err := scoped(h.db, c).Where("id = ?", webhookIDParam(c)).First(&webhook).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
 		return
 	}
 
 	var input struct {
-		Name          string   `json:"name"`
-		URL           string   `json:"url"`
-		Subscriptions []string `json:"subscriptions"`
+		Name           string   `json:"name"`
+		URL            string   `json:"url"`
+		Subscriptions  []string `json:"subscriptions"`
+		IncludePrivate *bool    `json:"include_private"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -112,6 +116,9 @@ func (h *WebhookHandler) Update(c *gin.Context) {
 	if input.Subscriptions != nil {
 		webhook.Subscriptions = input.Subscriptions
 	}
+	if input.IncludePrivate != nil {
+		webhook.IncludePrivate = *input.IncludePrivate
+	}
 
 	if err := h.db.Save(&webhook).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -123,10 +130,7 @@ func (h *WebhookHandler) Update(c *gin.Context) {
 
 // Delete a webhook
 func (h *WebhookHandler) Delete(c *gin.Context) {
-	id := c.Param("id")
-	accountID := c.GetString("account_id")
-
-	result := h.db.Where("id = ? AND account_id = ?", id, accountID).Delete(&models.Webhook{})
+	result := scoped(h.db, c).Where("id = ?", webhookIDParam(c)).Delete(&models.Webhook{})
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -140,21 +144,50 @@ func (h *WebhookHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
 }
 
+// webhookIDParam returns the webhook ID from /webhooks/:id or /accounts/:id/webhooks/:webhook_id
+func webhookIDParam(c *gin.Context) string {
+	if id := c.Param("webhook_id"); id != "" {
+		return id
+	}
+	return c.Param("id")
+}
+
 // DispatchEvent sends event to all matching webhooks
 func (h *WebhookHandler) DispatchEvent(accountID uuid.UUID, eventName string, payload interface{}) {
+	dispatchWebhooks(h.db, accountID, eventName, payload)
+}
+
+// dispatchWebhooks sends an event to the webhooks of an account subscribed to it.
+// Private notes only go to webhooks that include them.
+func dispatchWebhooks(db *gorm.DB, accountID uuid.UUID, eventName string, payload interface{}) {
 	var webhooks []models.Webhook
-	h.db.Where("account_id = ?", accountID).Find(&webhooks)
+	db.Where("account_id = ?", accountID).Find(&webhooks)
 
+	private := isPrivateNote(payload)
 	for _, webhook := range webhooks {
 		// Check if webhook subscribes to this event
 		if !containsSubscription(webhook.Subscriptions, eventName) && len(webhook.Subscriptions) > 0 {
 			continue
 		}
+		if private && !webhook.IncludePrivate {
+			continue
+		}
 
 		go sendWebhookRequest(webhook.URL, eventName, payload)
 	}
 }
 
+// isPrivateNote reports whether an event payload is a private note
+func isPrivateNote(payload interface{}) bool {
+	switch message := payload.(type) {
+	case *models.Message:
+		return message.Private
+	case models.Message:
+		return message.Private
+	}
+	return false
+}
+
 func containsSubscription(subscriptions []string, event string) bool {
 	for _, s := range subscriptions {
 		if s == event || s == "*" {
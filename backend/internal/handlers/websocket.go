@@ -1,85 +1,230 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/nakamura/chatwoot-go/internal/channels"
 	"github.com/nakamura/chatwoot-go/internal/config"
 	"github.com/nakamura/chatwoot-go/internal/middleware"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/presence"
 	ws "github.com/nakamura/chatwoot-go/internal/websocket"
+	"gorm.io/gorm"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // TODO: Implement proper origin checking
-	},
-}
-
 type WebSocketHandler struct {
-	hub *ws.Hub
-	cfg *config.Config
+	db       *gorm.DB
+	hub      *ws.Hub
+	cfg      *config.Config
+	channels *channels.Registry
+	presence *presence.Tracker
+	upgrader websocket.Upgrader
 }
 
-func NewWebSocketHandler(hub *ws.Hub, cfg *config.Config) *WebSocketHandler {
-	return &WebSocketHandler{hub: hub, cfg: cfg}
+func NewWebSocketHandler(db *gorm.DB, hub *ws.Hub, cfg *config.Config, channelRegistry *channels.Registry, tracker *presence.Tracker) *WebSocketHandler {
+	h := &WebSocketHandler{db: db, hub: hub, cfg: cfg, channels: channelRegistry, presence: tracker}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		// Clients asking for none get the native protocol
+		Subprotocols: []string{ws.ActionCableSubprotocol},
+		CheckOrigin:  h.checkOrigin,
+	}
+	return h
 }
 
-// HandleWebSocket upgrades HTTP connection to WebSocket
-func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
-	var userID uuid.UUID
-	var err error
+// checkOrigin accepts handshakes from the frontend, from pages of the server itself
+// (the bundled SPA) and from ALLOWED_ORIGINS. Requests without an Origin do not come
+// from browsers, and still need a token.
+func (h *WebSocketHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
 
-	// 1. Try to get token from query param (Standard Chatwoot frontend behavior)
-	tokenStr := c.Query("token")
-	if tokenStr != "" {
-		claims := &middleware.Claims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(h.cfg.JWTSecret), nil
-		})
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			return
-		}
-		userID = claims.UserID
-	} else {
-		// 2. Fallback to user_id query param (For dev/testing tools)
-		userIDStr := c.Query("user_id")
-		if userIDStr == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "token or user_id required"})
-			return
-		}
-		userID, err = uuid.Parse(userIDStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
-			return
+	for _, allowed := range append([]string{h.cfg.FrontendURL}, h.cfg.AllowedOrigins...) {
+		a, err := url.Parse(strings.TrimRight(allowed, "/"))
+		if err == nil && strings.EqualFold(a.Scheme, u.Scheme) && strings.EqualFold(a.Host, u.Host) {
+			return true
 		}
 	}
+	return false
+}
+
+// HandleWebSocket upgrades HTTP connection to WebSocket. A valid session JWT is
+// required, in the token query param (browsers cannot set headers on WebSockets) or
+// the Authorization header. The connection is closed when the token expires.
+func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
+	claims, role, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
 
 	// Upgrade connection
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return
 	}
 
 	// Create client
 	client := &ws.Client{
-		ID:     uuid.New(),
-		UserID: userID,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		Hub:    h.hub,
-		Rooms:  make(map[string]bool),
+		ID:        uuid.New(),
+		UserID:    claims.UserID,
+		AccountID: claims.AccountID,
+		SessionID: claims.SessionID,
+		Role:      role,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		Hub:       h.hub,
+		Rooms:     make(map[string]bool),
+	}
+	if conn.Subprotocol() == ws.ActionCableSubprotocol {
+		client.Protocol = ws.NewActionCable()
+	}
+	client.ResolveRoom = func(room string) []string {
+		return h.resolveRoom(client, room)
+	}
+	var user models.User
+	h.db.Select("name", "display_name").First(&user, "id = ?", claims.UserID)
+	client.OnTyping = func(conversationID uuid.UUID, typing, private bool) {
+		h.relayTyping(client, &user, conversationID, typing, private)
+	}
+	if h.presence != nil {
+		client.OnClose = func() {
+			h.presence.Disconnected(client.UserID, client.ID)
+		}
+	}
+	if claims.ExpiresAt != nil {
+		client.CloseAt(claims.ExpiresAt.Time, ws.CloseTokenExpired, "token expired")
 	}
 
 	// Register client
 	h.hub.Register <- client
+	// Before the connection can close, so that Disconnected always comes after
+	if h.presence != nil {
+		h.presence.Connected(client.UserID, client.ID)
+	}
 
 	// Start pumps
 	go client.WritePump()
 	go client.ReadPump()
 }
+
+// authenticate checks the session JWT of a real-time connection, from the token query
+// param or the Authorization header, and answers the request when it is not valid
+func (h *WebSocketHandler) authenticate(c *gin.Context) (*middleware.Claims, string, bool) {
+	tokenStr := c.Query("token")
+	if tokenStr == "" {
+		tokenStr = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if tokenStr == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token required"})
+		return nil, "", false
+	}
+
+	claims, err := middleware.ParseToken(h.cfg, tokenStr)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return nil, "", false
+	}
+	role, err := middleware.Authorize(h.db, claims)
+	if errors.Is(err, middleware.ErrTokenRevoked) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked, please sign in again"})
+		return nil, "", false
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this account"})
+		return nil, "", false
+	}
+	if middleware.TwoFactorSetupRequired(h.db, claims.UserID, claims.AccountID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account requires two-factor authentication", "code": "two_factor_setup_required"})
+		return nil, "", false
+	}
+	return claims, role, true
+}
+
+// resolveRoom returns the rooms a client joins when subscribing to a room, or nil
+// when it may not. "notifications" joins the account room and the rooms of the
+// inboxes the user can see. The role is read again, since it may have changed since
+// the connection was opened.
+func (h *WebSocketHandler) resolveRoom(client *ws.Client, room string) []string {
+	role, err := middleware.AccountRole(h.db, client.UserID, client.AccountID)
+	if err != nil {
+		return nil
+	}
+
+	if room == ws.NotificationsRoom {
+		rooms := []string{ws.AccountRoom(client.AccountID)}
+		for _, inboxID := range visibleInboxIDsAs(h.db, client.UserID, client.AccountID, role) {
+			rooms = append(rooms, ws.InboxRoom(inboxID))
+		}
+		return rooms
+	}
+
+	var allowed bool
+	switch kind, id := ws.RoomKind(room); kind {
+	case "account":
+		allowed = id == client.AccountID
+	case "inbox":
+		allowed = canAccessInboxAs(h.db, client.UserID, client.AccountID, role, id)
+	case "conversation":
+		allowed = canAccessConversation(h.db, client.UserID, client.AccountID, role, id)
+	}
+	if !allowed {
+		return nil
+	}
+	return []string{room}
+}
+
+// relayTyping shows the other agents in the conversation that the agent is typing,
+// and the contact too when the channel supports it and the agent is not writing a
+// private note
+func (h *WebSocketHandler) relayTyping(client *ws.Client, user *models.User, conversationID uuid.UUID, typing, private bool) {
+	eventType := "typing.stop"
+	if typing {
+		eventType = "typing.start"
+	}
+	name := user.DisplayName
+	if name == "" {
+		name = user.Name
+	}
+	h.hub.BroadcastTransient(ws.ConversationRoom(conversationID), eventType, map[string]interface{}{
+		"conversation_id": conversationID,
+		"user":            map[string]interface{}{"id": client.UserID, "name": name},
+		"private":         private,
+	})
+
+	if private || h.channels == nil {
+		return
+	}
+	go func() {
+		var conversation models.Conversation
+		if err := h.db.Preload("Inbox").Preload("Contact").
+			First(&conversation, "id = ? AND account_id = ?", conversationID, client.AccountID).Error; err != nil {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err := h.channels.SendTyping(ctx, &conversation.Inbox, &conversation.Contact, typing)
+		if err != nil && !errors.Is(err, channels.ErrNoProvider) {
+			log.Printf("Failed to send typing indicator for conversation %s: %v", conversationID, err)
+		}
+	}()
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nakamura/chatwoot-go/internal/config"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	h := NewWebSocketHandler(nil, nil, &config.Config{
+		FrontendURL:    "http://localhost:5173/",
+		AllowedOrigins: []string{"https://app.example.com"},
+	}, nil, nil)
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://localhost:5173", true},
+		{"HTTP://LOCALHOST:5173", true},
+		{"https://app.example.com", true},
+		{"http://api.example.com", true}, // the server's own pages
+		{"https://localhost:5173", false},
+		{"http://localhost:5174", false},
+		{"http://app.example.com", false},
+		{"https://evil.example.com", false},
+		{"null", false},
+		{"://bad", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://api.example.com/cable", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := h.checkOrigin(r); got != tt.want {
+				t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
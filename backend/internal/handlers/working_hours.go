@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/schedule"
+	"gorm.io/gorm"
+)
+
+// GetWorkingHours returns the weekly schedule and holidays of an inbox
+func (h *InboxHandler) GetWorkingHours(c *gin.Context) {
+	inbox, ok := h.findVisibleInbox(c)
+	if !ok {
+		return
+	}
+
+	var hours []models.WorkingHour
+	h.db.Where("inbox_id = ?", inbox.ID).Order("day_of_week asc, open_time asc").Find(&hours)
+
+	var holidays []models.InboxHoliday
+	h.db.Where("inbox_id = ?", inbox.ID).Order("date asc").Find(&holidays)
+
+	c.JSON(http.StatusOK, gin.H{
+		"working_hours_enabled": inbox.WorkingHoursEnabled,
+		"timezone":              inbox.Timezone,
+		"out_of_office_message": inbox.OutOfOfficeMessage,
+		"working_hours":         hours,
+		"holidays":              holidays,
+	})
+}
+
+// UpdateWorkingHours replaces the weekly schedule and holidays of an inbox
+func (h *InboxHandler) UpdateWorkingHours(c *gin.Context) {
+	inbox, ok := h.findAccountInbox(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		WorkingHoursEnabled *bool   `json:"working_hours_enabled"`
+		Timezone            *string `json:"timezone"`
+		OutOfOfficeMessage  *string `json:"out_of_office_message"`
+		WorkingHours        *[]struct {
+			DayOfWeek int    `json:"day_of_week"`
+			OpenTime  string `json:"open_time"`
+			CloseTime string `json:"close_time"`
+		} `json:"working_hours"`
+		Holidays *[]struct {
+			Date string `json:"date"`
+			Name string `json:"name"`
+		} `json:"holidays"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.WorkingHoursEnabled != nil {
+		updates["working_hours_enabled"] = *input.WorkingHoursEnabled
+	}
+	if input.Timezone != nil {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil || *input.Timezone == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+			return
+		}
+		updates["timezone"] = *input.Timezone
+	}
+	if input.OutOfOfficeMessage != nil {
+		updates["out_of_office_message"] = *input.OutOfOfficeMessage
+	}
+
+	var hours []models.WorkingHour
+	if input.WorkingHours != nil {
+		for _, wh := range *input.WorkingHours {
+			hours = append(hours, models.WorkingHour{
+				InboxID:   inbox.ID,
+				DayOfWeek: wh.DayOfWeek,
+				OpenTime:  wh.OpenTime,
+				CloseTime: wh.CloseTime,
+			})
+		}
+	}
+
+	var holidays []models.InboxHoliday
+	if input.Holidays != nil {
+		for _, holiday := range *input.Holidays {
+			if _, err := time.Parse(schedule.DateLayout, holiday.Date); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid holiday date (YYYY-MM-DD expected): " + holiday.Date})
+				return
+			}
+			holidays = append(holidays, models.InboxHoliday{
+				InboxID: inbox.ID,
+				Date:    holiday.Date,
+				Name:    holiday.Name,
+			})
+		}
+	}
+
+	// Validate intervals before touching the database
+	if _, err := schedule.New("UTC", hours, holidays); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(inbox).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if input.WorkingHours != nil {
+			if err := tx.Unscoped().Where("inbox_id = ?", inbox.ID).Delete(&models.WorkingHour{}).Error; err != nil {
+				return err
+			}
+			if len(hours) > 0 {
+				if err := tx.Create(&hours).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if input.Holidays != nil {
+			if err := tx.Unscoped().Where("inbox_id = ?", inbox.ID).Delete(&models.InboxHoliday{}).Error; err != nil {
+				return err
+			}
+			if len(holidays) > 0 {
+				if err := tx.Create(&holidays).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update working hours"})
+		return
+	}
+
+	h.GetWorkingHours(c)
+}
+
+// IsOpen reports whether an inbox is currently open, and when it opens next
+func (h *InboxHandler) IsOpen(c *gin.Context) {
+	inbox, ok := h.findVisibleInbox(c)
+	if !ok {
+		return
+	}
+
+	sched, err := schedule.Load(h.db, inbox.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if sched == nil {
+		// Working hours disabled: always open
+		c.JSON(http.StatusOK, gin.H{"open": true, "working_hours_enabled": false, "timezone": inbox.Timezone})
+		return
+	}
+
+	response := gin.H{
+		"open":                  sched.IsOpen(now),
+		"working_hours_enabled": true,
+		"timezone":              inbox.Timezone,
+		"next_open_at":          nil,
+	}
+	if nextOpen, ok := sched.NextOpen(now); ok {
+		response["next_open_at"] = nextOpen
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,104 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/config"
+)
+
+// Message is an email to send
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by MAILER_DRIVER (log, file or smtp)
+func New(cfg *config.Config) Mailer {
+	switch cfg.MailerDriver {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailerFrom,
+		}
+	case "file":
+		return &FileMailer{Dir: cfg.MailerFileDir, From: cfg.MailerFrom}
+	default:
+		return &LogMailer{From: cfg.MailerFrom}
+	}
+}
+
+// LogMailer writes emails to the server log instead of sending them (local development)
+type LogMailer struct {
+	From string
+}
+
+// Send logs the email
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 Mail from %s to %s: %s\n%s", m.From, msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// FileMailer writes each email as an .eml file in Dir (local development)
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send writes the email to a new file
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	raw, err := format(m.From, msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.New().String()[:8])
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(raw), 0o644)
+}
+
+// ErrInvalidHeader is returned for emails whose sender, recipient or subject contain a
+// line break, which would let them inject headers
+var ErrInvalidHeader = errors.New("mailer: line break in email header")
+
+// format renders a plain text RFC 5322 message with CRLF line endings. The subject is
+// RFC 2047 encoded when it is not plain ASCII.
+func format(from string, msg Message) (string, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return "", ErrInvalidHeader
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Text, "\r\n", "\n"), "\n", "\r\n"))
+	return b.String(), nil
+}
@@ -0,0 +1,118 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	const from = "Chatwoot <no-reply@example.com>"
+
+	tests := []struct {
+		name        string
+		from        string
+		msg         Message
+		wantErr     error
+		wantHeaders []string
+		wantBody    string
+	}{
+		{
+			name:        "plain ascii",
+			from:        from,
+			msg:         Message{To: "ana@example.com", Subject: "Reset your password", Text: "Hi Ana,\n\nClick the link.\n"},
+			wantHeaders: []string{"From: " + from, "To: ana@example.com", "Subject: Reset your password", "Content-Type: text/plain; charset=UTF-8"},
+			wantBody:    "Hi Ana,\r\n\r\nClick the link.\r\n",
+		},
+		{
+			name:        "encoded subject",
+			from:        from,
+			msg:         Message{To: "jose@example.com", Subject: "Você foi convidado", Text: "Olá"},
+			wantHeaders: []string{"Subject: =?utf-8?q?Voc=C3=AA_foi_convidado?="},
+			wantBody:    "Olá",
+		},
+		{
+			name:     "line endings already CRLF",
+			from:     from,
+			msg:      Message{To: "ana@example.com", Subject: "Hi", Text: "one\r\ntwo\n"},
+			wantBody: "one\r\ntwo\r\n",
+		},
+		{
+			name:    "line break in subject",
+			from:    from,
+			msg:     Message{To: "ana@example.com", Subject: "Hi\r\nBcc: victim@example.com"},
+			wantErr: ErrInvalidHeader,
+		},
+		{
+			name:    "line break in recipient",
+			from:    from,
+			msg:     Message{To: "ana@example.com\nBcc: victim@example.com", Subject: "Hi"},
+			wantErr: ErrInvalidHeader,
+		},
+		{
+			name:    "line break in sender",
+			from:    "Chatwoot\r\nBcc: victim@example.com",
+			msg:     Message{To: "ana@example.com", Subject: "Hi"},
+			wantErr: ErrInvalidHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := format(tt.from, tt.msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("format error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			headers, body, ok := strings.Cut(raw, "\r\n\r\n")
+			if !ok {
+				t.Fatalf("no blank line between headers and body:\n%s", raw)
+			}
+			lines := strings.Split(headers, "\r\n")
+			for _, want := range tt.wantHeaders {
+				if !contains(lines, want) {
+					t.Errorf("header %q missing from:\n%s", want, headers)
+				}
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mails")
+	m := &FileMailer{Dir: dir, From: "Chatwoot <no-reply@example.com>"}
+
+	if err := m.Send(context.Background(), Message{To: "ana@example.com", Subject: "Hi", Text: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send(context.Background(), Message{To: "ana@example.com", Subject: "Hi\nBcc: x@example.com"}); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Send with a header injection = %v, want %v", err, ErrInvalidHeader)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("written files = %v (%v), want one", files, err)
+	}
+	raw, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(raw), "To: ana@example.com\r\n") || !strings.HasSuffix(string(raw), "\r\n\r\nHello") {
+		t.Errorf("unexpected email:\n%s", raw)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
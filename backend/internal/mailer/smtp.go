@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPMailer sends emails through an SMTP server. STARTTLS is used when the server offers it.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the email
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid MAILER_FROM: %w", err)
+	}
+
+	raw, err := format(m.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	// net/smtp has no context support; run the delivery so cancellation is honored
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, from.Address, []string{msg.To}, []byte(raw))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
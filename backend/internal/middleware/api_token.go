@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/security"
+	"gorm.io/gorm"
+)
+
+// ErrTokenExpired is returned for API tokens past their expiry
+var ErrTokenExpired = errors.New("token has expired")
+
+// lastUsedResolution limits how often LastUsedAt is written for a busy token
+const lastUsedResolution = time.Minute
+
+// scopedRoute grants API tokens access to the routes under a prefix. GET requests
+// need the read scope, any other method the write scope; an empty scope denies.
+type scopedRoute struct {
+	prefix string
+	read   string
+	write  string
+}
+
+// apiTokenRoutes lists the routes API tokens may call. Anything else (profile, token
+// management, accounts, admin) is only reachable with a user session.
+var apiTokenRoutes = []scopedRoute{
+	{"/api/v1/conversations/:id/messages", models.ScopeMessagesRead, ""},
+	{"/api/v1/conversations", models.ScopeConversationsRead, models.ScopeConversationsWrite},
+	{"/api/v1/messages", models.ScopeMessagesRead, models.ScopeMessagesWrite},
+	{"/api/v1/contacts/:id/conversations", models.ScopeConversationsRead, ""},
+	{"/api/v1/contacts", models.ScopeContactsRead, models.ScopeContactsWrite},
+	{"/api/v1/inboxes", models.ScopeInboxesRead, ""},
+}
+
+// RequiredScope returns the scope an API token needs to call a route, identified by
+// its method and gin full path. It returns false when tokens may not call the route.
+func RequiredScope(method, fullPath string) (string, bool) {
+	for _, route := range apiTokenRoutes {
+		if fullPath != route.prefix && !strings.HasPrefix(fullPath, route.prefix+"/") {
+			continue
+		}
+		scope := route.write
+		if method == http.MethodGet {
+			scope = route.read
+		}
+		return scope, scope != ""
+	}
+	return "", false
+}
+
+// ValidScope reports whether a scope exists
+func ValidScope(scope string) bool {
+	for _, s := range models.AccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAccessToken looks up an unexpired API token by its plaintext value
+func FindAccessToken(db *gorm.DB, raw string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	if err := db.Where("token_hash = ?", security.HashToken(raw)).First(&token).Error; err != nil {
+		return nil, err
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+	return &token, nil
+}
+
+// TouchAccessToken records that a token was used
+func TouchAccessToken(db *gorm.DB, token *models.AccessToken) {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < lastUsedResolution {
+		return
+	}
+	token.LastUsedAt = &now
+	db.Model(token).UpdateColumn("last_used_at", now)
+}
+
+// isJWT tells session JWTs (header.payload.signature) apart from API tokens
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// authenticateAPIToken authenticates a request made with a personal API token. The
+// token acts in its account with the owner's current role there, limited to its scopes.
+func authenticateAPIToken(c *gin.Context, db *gorm.DB, raw string) {
+	token, err := FindAccessToken(db, raw)
+	if errors.Is(err, ErrTokenExpired) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API token expired"})
+		c.Abort()
+		return
+	}
+	if err != nil || token.OwnerType != "User" || token.AccountID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
+		c.Abort()
+		return
+	}
+
+	role, err := AccountRole(db, token.OwnerID, *token.AccountID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this account"})
+		c.Abort()
+		return
+	}
+
+	scope, ok := RequiredScope(c.Request.Method, c.FullPath())
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available to API tokens"})
+		c.Abort()
+		return
+	}
+	if !token.HasScope(scope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API token is missing the " + scope + " scope", "code": "insufficient_scope"})
+		c.Abort()
+		return
+	}
+
+	if TwoFactorSetupRequired(db, token.OwnerID, *token.AccountID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This account requires two-factor authentication, enable it in your profile",
+			"code":  "two_factor_setup_required",
+		})
+		c.Abort()
+		return
+	}
+
+	var user models.User
+	if err := db.Select("id", "email").First(&user, "id = ?", token.OwnerID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
+		c.Abort()
+		return
+	}
+
+	TouchAccessToken(db, token)
+
+	c.Set("user_id", user.ID.String())
+	c.Set("email", user.Email)
+	c.Set("role", role)
+	c.Set("account_id", token.AccountID.String())
+	c.Set("auth_method", "api_token")
+	c.Set("token_scopes", strings.Fields(token.Scopes))
+
+	c.Next()
+}
@@ -1,13 +1,17 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/nakamura/chatwoot-go/internal/config"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"gorm.io/gorm"
 )
 
 // Claims represents JWT claims
@@ -16,11 +20,83 @@ type Claims struct {
 	Email     string    `json:"email"`
 	Role      string    `json:"role"`
 	AccountID uuid.UUID `json:"account_id"`
+	SessionID uuid.UUID `json:"sid"`
+	// TokenVersion must match User.TokenVersion
+	TokenVersion int `json:"ver"`
 	jwt.RegisteredClaims
 }
 
-// AuthMiddleware validates JWT token
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// ParseToken validates the signature and expiry of a session JWT and returns its claims
+func ParseToken(cfg *config.Config, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// AccountRole returns the role of a user in an account. It fails when the user is
+// not (or no longer) a member of the account.
+func AccountRole(db *gorm.DB, userID, accountID uuid.UUID) (string, error) {
+	var accountUser models.AccountUser
+	if err := db.Where("account_id = ? AND user_id = ?", accountID, userID).First(&accountUser).Error; err != nil {
+		return "", err
+	}
+	return accountUser.Role, nil
+}
+
+// ErrTokenRevoked is returned for tokens whose session was revoked or whose version is outdated
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// Authorize checks that the session of a validated token is still active and returns
+// the user's role in the token's account.
+func Authorize(db *gorm.DB, claims *Claims) (string, error) {
+	var user models.User
+	if err := db.Select("id", "token_version").First(&user, "id = ?", claims.UserID).Error; err != nil {
+		return "", err
+	}
+	if claims.TokenVersion != user.TokenVersion || claims.SessionID == uuid.Nil {
+		return "", ErrTokenRevoked
+	}
+
+	var active int64
+	db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", claims.SessionID, claims.UserID, time.Now()).
+		Count(&active)
+	if active == 0 {
+		return "", ErrTokenRevoked
+	}
+
+	return AccountRole(db, claims.UserID, claims.AccountID)
+}
+
+// TwoFactorSetupRequired reports whether the account requires two-factor authentication
+// and the user has not enabled it yet
+func TwoFactorSetupRequired(db *gorm.DB, userID, accountID uuid.UUID) bool {
+	var count int64
+	db.Model(&models.User{}).
+		Joins("JOIN accounts ON accounts.id = ?", accountID).
+		Where("users.id = ? AND accounts.two_factor_required AND NOT users.two_factor_enabled", userID).
+		Count(&count)
+	return count > 0
+}
+
+// allowedDuringTwoFactorSetup lists the routes usable before enrolling in two-factor
+// authentication when the account requires it: profile (including enrollment) and session routes
+func allowedDuringTwoFactorSetup(path string) bool {
+	return strings.HasPrefix(path, "/api/v1/profile") || strings.HasPrefix(path, "/api/v1/auth/")
+}
+
+// AuthMiddleware validates JWT token, or a personal API token (see authenticateAPIToken).
+// The role of the request is the user's role in the account of the token, read from
+// account_users so that changes apply immediately.
+func AuthMiddleware(cfg *config.Config, db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Allow OPTIONS requests for CORS preflight
 		if c.Request.Method == "OPTIONS" {
@@ -28,6 +104,12 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// Personal API tokens may also be sent Chatwoot style
+		if apiToken := c.GetHeader("api_access_token"); apiToken != "" {
+			authenticateAPIToken(c, db, apiToken)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -44,35 +126,52 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
+		if !isJWT(tokenString) {
+			authenticateAPIToken(c, db, tokenString)
+			return
+		}
 
 		// Parse and validate token
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := ParseToken(cfg, tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
-		// Extract claims
-		if claims, ok := token.Claims.(*Claims); ok {
-			c.Set("user_id", claims.UserID.String())
-			c.Set("email", claims.Email)
-			c.Set("role", claims.Role)
-			c.Set("account_id", claims.AccountID.String())
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		role, err := Authorize(db, claims)
+		if errors.Is(err, ErrTokenRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked, please sign in again"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No access to this account"})
+			c.Abort()
+			return
+		}
+
+		if !allowedDuringTwoFactorSetup(c.FullPath()) && TwoFactorSetupRequired(db, claims.UserID, claims.AccountID) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "This account requires two-factor authentication, enable it in your profile",
+				"code":  "two_factor_setup_required",
+			})
 			c.Abort()
 			return
 		}
 
+		c.Set("user_id", claims.UserID.String())
+		c.Set("email", claims.Email)
+		c.Set("role", role)
+		c.Set("account_id", claims.AccountID.String())
+		c.Set("session_id", claims.SessionID.String())
+		c.Set("auth_method", "session")
+
 		c.Next()
 	}
 }
 
-// RequireRole checks if user has required role
+// RequireRole checks if user has required role in the active account
 func RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("role")
@@ -94,3 +193,16 @@ func RequireRole(roles ...string) gin.HandlerFunc {
 		c.Abort()
 	}
 }
+
+// RequireActiveAccount rejects requests whose :id path param is not the account of
+// the token, so /accounts/:id/... routes cannot reach into another account
+func RequireActiveAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("id") != c.GetString("account_id") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account mismatch"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -60,6 +61,10 @@ type Account struct {
 	AutoResolveTime  int    `gorm:"default:40" json:"auto_resolve_time"` // hours
 	FeatureFlags     JSONB  `gorm:"type:jsonb" json:"feature_flags"`
 	CustomAttributes JSONB  `gorm:"type:jsonb" json:"custom_attributes"`
+	// Users must enable two-factor authentication to work in the account
+	TwoFactorRequired bool `gorm:"default:false" json:"two_factor_required"`
+	// Users must sign in through the account's OIDC provider
+	PasswordLoginDisabled bool `gorm:"default:false" json:"password_login_disabled"`
 
 	// Relationships
 	Users         []User         `gorm:"many2many:account_users;" json:"users,omitempty"`
@@ -77,29 +82,35 @@ type User struct {
 	PasswordHash     string `gorm:"not null" json:"-"`
 	DisplayName      string `json:"display_name"`
 	Avatar           string `json:"avatar"`
-	Role             string `gorm:"default:'agent'" json:"role"`     // administrator, agent, supervisor
-	AccessToken      string `gorm:"uniqueIndex" json:"access_token"` // Token único do usuário (estilo Chatwoot original)
+	Role             string `gorm:"default:'agent'" json:"role"` // administrator, agent, supervisor
 	CustomAttributes JSONB  `gorm:"type:jsonb" json:"custom_attributes"`
 	Availability     string `gorm:"default:'online'" json:"availability"` // online, busy, offline
 	UISettings       JSONB  `gorm:"type:jsonb" json:"ui_settings"`
 
+	// Presence, maintained from live WebSocket connections
+	Online       bool       `gorm:"not null;default:false" json:"online"`
+	LastSeenAt   *time.Time `json:"last_seen_at"`
+	AutoOffline  bool       `gorm:"not null;default:true" json:"auto_offline"` // go offline after disconnecting
+	AutoAwayFrom string     `json:"-"`                                         // availability to restore on reconnect after going offline automatically
+
+	// Security
+	PasswordChangedAt *time.Time `json:"-"`
+	TokenVersion      int        `gorm:"not null;default:0" json:"-"` // incremented to invalidate every token of the user
+	TwoFactorEnabled  bool       `gorm:"default:false" json:"two_factor_enabled"`
+	TwoFactorSecret   string     `json:"-"` // base32 TOTP secret, set on setup and kept once enabled
+	TwoFactorLastStep int64      `json:"-"` // last accepted TOTP step, to refuse replays
+
+	// Brute-force protection
+	FailedLoginAttempts int        `gorm:"not null;default:0" json:"-"`
+	LastFailedLoginAt   *time.Time `json:"-"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+
 	// Relationships
 	Accounts              []Account      `gorm:"many2many:account_users;" json:"accounts,omitempty"`
 	AssignedConversations []Conversation `gorm:"foreignKey:AssigneeID" json:"assigned_conversations,omitempty"`
 	Messages              []Message      `gorm:"foreignKey:SenderID" json:"messages,omitempty"`
 }
 
-// BeforeCreate hook for User
-func (u *User) BeforeCreate(tx *gorm.DB) error {
-	if err := u.BaseModel.BeforeCreate(tx); err != nil {
-		return err
-	}
-	if u.AccessToken == "" {
-		u.AccessToken = uuid.New().String()
-	}
-	return nil
-}
-
 // AccountUser is the join table for Account and User
 type AccountUser struct {
 	AccountID uuid.UUID `gorm:"type:uuid;primaryKey"`
@@ -109,6 +120,125 @@ type AccountUser struct {
 	UpdatedAt time.Time
 }
 
+// Session is a signed-in device. Access tokens carry the session ID and stop working
+// as soon as the session is revoked; refresh tokens rotate within the session.
+type Session struct {
+	BaseModel
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	AccountID  uuid.UUID  `gorm:"type:uuid;not null" json:"account_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	AuthMethod string     `gorm:"default:'password'" json:"auth_method"` // password, sso
+	// SSOAccountID is the account whose identity provider signed the session in
+	SSOAccountID *uuid.UUID `gorm:"type:uuid" json:"sso_account_id"`
+}
+
+// OIDCProvider is the OpenID Connect single sign-on configuration of an account
+type OIDCProvider struct {
+	BaseModel
+	AccountID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"account_id"`
+	Issuer         string    `gorm:"not null" json:"issuer"`
+	ClientID       string    `gorm:"not null" json:"client_id"`
+	ClientSecret   string    `json:"-"`
+	AllowedDomains string    `json:"allowed_domains"`                // space separated email domains, empty allows any
+	RoleClaim      string    `json:"role_claim"`                     // claim holding the user's groups/roles, e.g. "groups"
+	RoleMapping    JSONB     `gorm:"type:jsonb" json:"role_mapping"` // claim value -> account role
+	DefaultRole    string    `gorm:"default:'agent'" json:"default_role"`
+	Enabled        bool      `gorm:"default:true" json:"enabled"`
+}
+
+// OIDCLoginRequest is a pending single sign-on, from the redirect to the identity
+// provider until its callback. Only the SHA-256 of the state is stored.
+type OIDCLoginRequest struct {
+	BaseModel
+	AccountID    uuid.UUID  `gorm:"type:uuid;not null" json:"account_id"`
+	StateHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	Nonce        string     `gorm:"not null" json:"-"`
+	CodeVerifier string     `gorm:"not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at"`
+}
+
+// RefreshToken is one refresh token of a session. A token is single use: refreshing
+// rotates it, and presenting a rotated token again revokes the whole session.
+type RefreshToken struct {
+	BaseModel
+	SessionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"`
+}
+
+// RecoveryCode is a single-use two-factor recovery code. Only its SHA-256 is stored.
+type RecoveryCode struct {
+	BaseModel
+	UserID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CodeHash string     `gorm:"not null;index" json:"-"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+// UnlockToken is a single-use link emailed when a user is locked out after too many
+// failed logins. Only the SHA-256 of the token is stored.
+type UnlockToken struct {
+	BaseModel
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// LoginFailure tracks the failed sign-ins of an email that has no user, so that unknown
+// emails are throttled and locked like existing users and responses do not reveal
+// which emails are registered
+type LoginFailure struct {
+	Email               string `gorm:"primaryKey"`
+	FailedLoginAttempts int    `gorm:"not null;default:0"`
+	LastFailedLoginAt   *time.Time
+	LockedUntil         *time.Time
+	UpdatedAt           time.Time `gorm:"index"`
+}
+
+// AuditLog records security-relevant events (lockouts, unlocks, ...)
+type AuditLog struct {
+	BaseModel
+	AccountID *uuid.UUID `gorm:"type:uuid;index" json:"account_id"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id"`
+	Action    string     `gorm:"not null;index" json:"action"` // user.locked, user.unlocked
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	Metadata  JSONB      `gorm:"type:jsonb" json:"metadata"`
+}
+
+// PasswordResetToken is a single-use password reset link. Only the SHA-256 of the token is stored.
+type PasswordResetToken struct {
+	BaseModel
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	RequestedIP string     `json:"requested_ip"`
+}
+
+// Invitation invites someone by email to join an account with a role.
+// Only the SHA-256 of the token is stored; the token itself is sent by email.
+type Invitation struct {
+	BaseModel
+	AccountID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"account_id"`
+	Email       string     `gorm:"not null;index" json:"email"`
+	Role        string     `gorm:"default:'agent'" json:"role"`
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	InvitedByID *uuid.UUID `gorm:"type:uuid" json:"invited_by_id"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+
+	// Relationships
+	Account   Account `json:"account,omitempty"`
+	InvitedBy *User   `gorm:"foreignKey:InvitedByID" json:"invited_by,omitempty"`
+}
+
 // Inbox represents a communication channel
 type Inbox struct {
 	BaseModel
@@ -124,11 +254,40 @@ type Inbox struct {
 	OutOfOfficeMessage         string    `json:"out_of_office_message"`
 	Timezone                   string    `gorm:"default:'UTC'" json:"timezone"`
 	AllowMessagesAfterResolved bool      `gorm:"default:true" json:"allow_messages_after_resolved"`
+	ReopenWindowHours          int       `gorm:"default:24" json:"reopen_window_hours"` // reopen resolved conversations within this window; 0 = no limit
 
 	// Relationships
 	Account       Account        `json:"account,omitempty"`
 	Conversations []Conversation `json:"conversations,omitempty"`
 	Contacts      []Contact      `gorm:"many2many:inbox_contacts;" json:"contacts,omitempty"`
+	Members       []User         `gorm:"many2many:inbox_members;" json:"members,omitempty"`
+}
+
+// InboxMember is the join table for Inbox and User.
+// Agents only see conversations of inboxes they are members of; administrators see all.
+type InboxMember struct {
+	InboxID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"inbox_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkingHour is an opening interval of an inbox on a day of the week, in the inbox timezone.
+// A day may have several intervals (e.g. 09:00-12:00 and 13:00-18:00); days without any are closed.
+type WorkingHour struct {
+	BaseModel
+	InboxID   uuid.UUID `gorm:"type:uuid;not null;index" json:"inbox_id"`
+	DayOfWeek int       `gorm:"not null" json:"day_of_week"` // 0 = Sunday ... 6 = Saturday
+	OpenTime  string    `gorm:"not null" json:"open_time"`   // HH:MM
+	CloseTime string    `gorm:"not null" json:"close_time"`  // HH:MM, 24:00 = end of day
+}
+
+// InboxHoliday closes an inbox for a whole day regardless of its weekly schedule
+type InboxHoliday struct {
+	BaseModel
+	InboxID uuid.UUID `gorm:"type:uuid;not null;index" json:"inbox_id"`
+	Date    string    `gorm:"size:10;not null" json:"date"` // YYYY-MM-DD in the inbox timezone
+	Name    string    `json:"name"`
 }
 
 // Contact represents a customer/contact
@@ -153,13 +312,17 @@ type Contact struct {
 // Conversation represents a conversation thread
 type Conversation struct {
 	BaseModel
-	AccountID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"account_id"`
-	InboxID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"inbox_id"`
-	ContactID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"contact_id"`
-	AssigneeID *uuid.UUID `gorm:"type:uuid;index" json:"assignee_id"`
-	TeamID     *uuid.UUID `gorm:"type:uuid;index" json:"team_id"`
-	Status     string     `gorm:"default:'open';index" json:"status"` // open, resolved, pending, snoozed
-	// Priority             string         `gorm:"default:'none'" json:"priority"`     // urgent, high, medium, low, none
+	AccountID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"account_id"`
+	InboxID              uuid.UUID  `gorm:"type:uuid;not null;index" json:"inbox_id"`
+	ContactID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"contact_id"`
+	AssigneeID           *uuid.UUID `gorm:"type:uuid;index" json:"assignee_id"`
+	TeamID               *uuid.UUID `gorm:"type:uuid;index" json:"team_id"`
+	Status               string     `gorm:"default:'open';index" json:"status"`   // open, resolved, pending, snoozed
+	Priority             string     `gorm:"default:'none';index" json:"priority"` // urgent, high, medium, low, none
+	SLAPolicyID          *uuid.UUID `gorm:"type:uuid;index" json:"sla_policy_id"`
+	FirstResponseDueAt   *time.Time `json:"first_response_due_at"`
+	ResolutionDueAt      *time.Time `json:"resolution_due_at"`
+	SLAStartedAt         *time.Time `json:"sla_started_at"` // start of the current SLA cycle (creation or last reopening)
 	DisplayID            int        `gorm:"autoIncrement" json:"display_id"`
 	AdditionalAttributes JSONB      `gorm:"type:jsonb" json:"additional_attributes"`
 	CustomAttributes     JSONB      `gorm:"type:jsonb" json:"custom_attributes"`
@@ -170,13 +333,46 @@ type Conversation struct {
 	SnoozedUntil         *time.Time `json:"snoozed_until"`
 
 	// Relationships
-	Account  Account   `json:"account,omitempty"`
-	Inbox    Inbox     `json:"inbox,omitempty"`
-	Contact  Contact   `json:"contact,omitempty"`
-	Assignee *User     `gorm:"foreignKey:AssigneeID" json:"assignee,omitempty"`
-	Team     *Team     `gorm:"foreignKey:TeamID" json:"team,omitempty"`
-	Messages []Message `json:"messages,omitempty"`
-	Labels   []Label   `gorm:"many2many:conversation_labels;" json:"labels,omitempty"`
+	Account   Account    `json:"account,omitempty"`
+	Inbox     Inbox      `json:"inbox,omitempty"`
+	Contact   Contact    `json:"contact,omitempty"`
+	Assignee  *User      `gorm:"foreignKey:AssigneeID" json:"assignee,omitempty"`
+	Team      *Team      `gorm:"foreignKey:TeamID" json:"team,omitempty"`
+	SLAPolicy *SLAPolicy `gorm:"foreignKey:SLAPolicyID" json:"sla_policy,omitempty"`
+	Messages  []Message  `json:"messages,omitempty"`
+	Labels    []Label    `gorm:"many2many:conversation_labels;" json:"labels,omitempty"`
+}
+
+// SLAPolicy defines response targets for the conversations of an account or a single inbox.
+// An inbox-specific policy takes precedence over the account-wide one (InboxID nil).
+type SLAPolicy struct {
+	BaseModel
+	AccountID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"account_id"`
+	InboxID              *uuid.UUID `gorm:"type:uuid;index" json:"inbox_id"`
+	Name                 string     `gorm:"not null" json:"name"`
+	Description          string     `json:"description"`
+	Priority             string     `gorm:"not null;default:''" json:"priority"`     // only conversations of this priority; empty for all
+	FirstResponseMinutes int        `json:"first_response_minutes"`                  // 0 = no target
+	ResolutionMinutes    int        `json:"resolution_minutes"`                      // 0 = no target
+	WarningMinutes       int        `gorm:"default:15" json:"warning_minutes"`       // warn this long before a deadline
+	BusinessHoursOnly    bool       `gorm:"default:true" json:"business_hours_only"` // count only the inbox working hours
+	Active               bool       `gorm:"default:true" json:"active"`
+
+	// Relationships
+	Account Account `json:"account,omitempty"`
+	Inbox   *Inbox  `gorm:"foreignKey:InboxID" json:"inbox,omitempty"`
+}
+
+// SLAEvent records a warning or breach of an SLA target on a conversation, once per
+// deadline: a reopened or re-prioritized conversation gets new deadlines and new events
+type SLAEvent struct {
+	BaseModel
+	AccountID      uuid.UUID `gorm:"type:uuid;not null;index" json:"account_id"`
+	ConversationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_sla_event_cycle" json:"conversation_id"`
+	SLAPolicyID    uuid.UUID `gorm:"type:uuid;not null;index" json:"sla_policy_id"`
+	Metric         string    `gorm:"not null;uniqueIndex:idx_sla_event_cycle" json:"metric"`     // first_response, resolution
+	EventType      string    `gorm:"not null;uniqueIndex:idx_sla_event_cycle" json:"event_type"` // warning, breached
+	DueAt          time.Time `gorm:"uniqueIndex:idx_sla_event_cycle" json:"due_at"`              // identifies the SLA cycle
 }
 
 // Message represents a message in a conversation
@@ -184,21 +380,66 @@ type Message struct {
 	BaseModel
 	ConversationID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"conversation_id"`
 	SenderID          *uuid.UUID `gorm:"type:uuid;index" json:"sender_id"`       // User ID if sent by agent
+	SenderType        string     `gorm:"index" json:"sender_type"`               // User, Contact, Bot (automated messages)
 	ContactID         *uuid.UUID `gorm:"type:uuid;index" json:"contact_id"`      // Contact ID if sent by customer
 	MessageType       string     `gorm:"default:'incoming'" json:"message_type"` // incoming, outgoing, activity, template
 	ContentType       string     `gorm:"default:'text'" json:"content_type"`     // text, input_select, cards, form, article, etc
 	Content           string     `gorm:"type:text" json:"content"`
 	Private           bool       `gorm:"default:false" json:"private"` // Internal note
-	Status            string     `gorm:"default:'sent'" json:"status"` // sent, delivered, read, failed
+	Status            string     `gorm:"default:'sent'" json:"status"` // pending, sent, delivered, read, failed
 	SourceID          string     `gorm:"index" json:"source_id"`       // External message ID
 	ContentAttributes JSONB      `gorm:"type:jsonb" json:"content_attributes"`
 	ExternalSourceID  string     `json:"external_source_id"`
+	EditedAt          *time.Time `json:"edited_at"`
 
 	// Relationships
-	Conversation Conversation `json:"conversation,omitempty"`
-	Sender       *User        `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
-	Contact      *Contact     `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
-	Attachments  []Attachment `json:"attachments,omitempty"`
+	Conversation Conversation      `json:"conversation,omitempty"`
+	Sender       *User             `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
+	Contact      *Contact          `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+	Attachments  []Attachment      `json:"attachments,omitempty"`
+	Revisions    []MessageRevision `json:"revisions,omitempty"`
+}
+
+// Deleted reports whether the message was deleted, leaving a tombstone
+func (m *Message) Deleted() bool {
+	deleted, _ := m.ContentAttributes["deleted"].(bool)
+	return deleted
+}
+
+// MessageRevision keeps the content a message had before an edit
+type MessageRevision struct {
+	BaseModel
+	MessageID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"message_id"`
+	Content    string     `gorm:"type:text" json:"content"`
+	EditedByID *uuid.UUID `gorm:"type:uuid" json:"edited_by_id"`
+}
+
+// Notification tells a user about something in an account that needs their attention
+type Notification struct {
+	BaseModel
+	AccountID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"account_id"`
+	UserID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	NotificationType string     `gorm:"not null" json:"notification_type"` // conversation_assignment, conversation_mention, conversation_creation, sla_breach
+	ActorID          *uuid.UUID `gorm:"type:uuid" json:"actor_id"`         // user who caused it, if any
+	ConversationID   *uuid.UUID `gorm:"type:uuid;index" json:"conversation_id"`
+	MessageID        *uuid.UUID `gorm:"type:uuid" json:"message_id"`
+	Data             JSONB      `gorm:"type:jsonb" json:"data"` // details, e.g. the metric of an SLA breach
+	ReadAt           *time.Time `gorm:"index" json:"read_at"`
+
+	// Relationships
+	Actor        *User         `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	Conversation *Conversation `gorm:"foreignKey:ConversationID" json:"conversation,omitempty"`
+}
+
+// NotificationSetting holds the notification preferences of a user in an account
+type NotificationSetting struct {
+	BaseModel
+	AccountID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_notification_setting" json:"account_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_notification_setting" json:"user_id"`
+	InApp     JSONB     `gorm:"type:jsonb" json:"in_app"` // notification type -> enabled; types not listed are enabled
+	Email     JSONB     `gorm:"type:jsonb" json:"email"`  // email type -> enabled; types not listed use their default
+
+	LastDigestAt *time.Time `json:"-"` // when the daily digest was last sent
 }
 
 // Attachment represents a file attachment
@@ -251,29 +492,62 @@ type Webhook struct {
 	URL           string     `gorm:"not null" json:"url"`
 	WebhookType   string     `gorm:"default:'account'" json:"webhook_type"` // account, inbox
 	Subscriptions []string   `gorm:"type:text[]" json:"subscriptions"`      // conversation_created, message_created, etc
+	// IncludePrivate lets the webhook receive private notes, for internal integrations
+	IncludePrivate bool `gorm:"not null;default:false" json:"include_private"`
 
 	// Relationships
 	Account Account `json:"account,omitempty"`
 	Inbox   *Inbox  `gorm:"foreignKey:InboxID" json:"inbox,omitempty"`
 }
 
-// AccessToken represents an API access token for users or platform apps
+// AccessToken is a personal API token. It acts in one account with the owner's role
+// there, limited to its scopes. Only the SHA-256 of the token is stored; the token
+// itself is returned once, on creation.
 type AccessToken struct {
 	BaseModel
-	OwnerID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"owner_id"`
-	OwnerType string     `gorm:"not null" json:"owner_type"` // User, PlatformApp
-	Token     string     `gorm:"uniqueIndex;not null" json:"token"`
-	Name      string     `json:"name"`       // Token name/description
-	ExpiresAt *time.Time `json:"expires_at"` // Optional expiration
+	OwnerID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"owner_id"`
+	OwnerType  string     `gorm:"not null" json:"owner_type"` // User, PlatformApp
+	AccountID  *uuid.UUID `gorm:"type:uuid;index" json:"account_id"`
+	Name       string     `json:"name"`                    // Token name/description
+	Scopes     string     `gorm:"type:text" json:"scopes"` // space separated, e.g. "messages:write contacts:read"
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Prefix     string     `gorm:"size:12" json:"prefix"` // first characters, to recognize the token
+	ExpiresAt  *time.Time `json:"expires_at"`            // Optional expiration
+	LastUsedAt *time.Time `json:"last_used_at"`
+
+	// Token is only set when the token is created
+	Token string `gorm:"-" json:"token,omitempty"`
 
 	// Relationships
 	User *User `gorm:"foreignKey:OwnerID" json:"user,omitempty"`
 }
 
-// BeforeCreate hook to generate token
-func (at *AccessToken) BeforeCreate(tx *gorm.DB) error {
-	if at.Token == "" {
-		at.Token = "v1_" + uuid.New().String()
+// API token scopes
+const (
+	ScopeConversationsRead  = "conversations:read"
+	ScopeConversationsWrite = "conversations:write"
+	ScopeMessagesRead       = "messages:read"
+	ScopeMessagesWrite      = "messages:write"
+	ScopeContactsRead       = "contacts:read"
+	ScopeContactsWrite      = "contacts:write"
+	ScopeInboxesRead        = "inboxes:read"
+	ScopeWebhooksIngest     = "webhooks:ingest"
+)
+
+// AccessTokenScopes lists every scope a token can be granted
+var AccessTokenScopes = []string{
+	ScopeConversationsRead, ScopeConversationsWrite,
+	ScopeMessagesRead, ScopeMessagesWrite,
+	ScopeContactsRead, ScopeContactsWrite,
+	ScopeInboxesRead, ScopeWebhooksIngest,
+}
+
+// HasScope reports whether the token was granted a scope
+func (at *AccessToken) HasScope(scope string) bool {
+	for _, s := range strings.Fields(at.Scopes) {
+		if s == scope {
+			return true
+		}
 	}
-	return nil
+	return false
 }
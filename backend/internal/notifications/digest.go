@@ -0,0 +1,133 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/mailer"
+	"github.com/nakamura/chatwoot-go/internal/models"
+)
+
+// digestLimit caps the conversations listed in a digest
+const digestLimit = 50
+
+// RunDigests sends the daily digests that are due every interval; it blocks and is meant
+// to run in its own goroutine
+func (s *Service) RunDigests(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.SendDigests(now)
+	}
+}
+
+// SendDigests emails the daily digest of unattended conversations to the users who turned
+// it on and did not get it yet today, once the digest hour (UTC) has passed. Any node may
+// run it; claiming the setting row makes sure each digest is sent once.
+func (s *Service) SendDigests(now time.Time) {
+	now = now.UTC()
+	if now.Hour() < s.digestHour || s.mailer == nil {
+		return
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var settings []models.NotificationSetting
+	if err := s.db.
+		Where("email ->> ? = 'true'", TypeDigest).
+		Where("last_digest_at IS NULL OR last_digest_at < ?", today).
+		Find(&settings).Error; err != nil {
+		log.Printf("Notifications: failed to load digest settings: %v", err)
+		return
+	}
+
+	for _, setting := range settings {
+		claimed := s.db.Model(&models.NotificationSetting{}).
+			Where("id = ? AND (last_digest_at IS NULL OR last_digest_at < ?)", setting.ID, today).
+			Update("last_digest_at", now)
+		if claimed.Error != nil || claimed.RowsAffected == 0 {
+			continue
+		}
+		if err := s.sendDigest(setting.AccountID, setting.UserID); err != nil {
+			log.Printf("Notifications: failed to send digest to user %s: %v", setting.UserID, err)
+		}
+	}
+}
+
+type digestConversation struct {
+	DisplayID int
+	Contact   string
+	Waiting   string
+	Assigned  bool
+	Link      string
+}
+
+type digestInbox struct {
+	Name          string
+	Conversations []digestConversation
+}
+
+// sendDigest emails a user the open conversations of their inboxes that have no assignee
+// or no reply yet. Nothing is sent when there are none.
+func (s *Service) sendDigest(accountID, userID uuid.UUID) error {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	var account models.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		return err
+	}
+	var member models.AccountUser
+	if err := s.db.First(&member, "account_id = ? AND user_id = ?", accountID, userID).Error; err != nil {
+		return nil // no longer a member
+	}
+
+	query := s.db.Preload("Inbox").Preload("Contact").
+		Where("account_id = ? AND status = ?", accountID, "open").
+		Where("assignee_id IS NULL OR first_reply_created_at IS NULL")
+	if member.Role != "administrator" {
+		query = query.Where("inbox_id IN (?)", s.db.Model(&models.InboxMember{}).Select("inbox_id").Where("user_id = ?", userID))
+	}
+	var conversations []models.Conversation
+	if err := query.Order("last_activity_at asc").Limit(digestLimit).Find(&conversations).Error; err != nil {
+		return err
+	}
+	if len(conversations) == 0 {
+		return nil
+	}
+
+	var inboxes []*digestInbox
+	byInbox := make(map[uuid.UUID]*digestInbox)
+	for _, conversation := range conversations {
+		inbox, ok := byInbox[conversation.InboxID]
+		if !ok {
+			inbox = &digestInbox{Name: conversation.Inbox.Name}
+			byInbox[conversation.InboxID] = inbox
+			inboxes = append(inboxes, inbox)
+		}
+		inbox.Conversations = append(inbox.Conversations, digestConversation{
+			DisplayID: conversation.DisplayID,
+			Contact:   conversation.Contact.Name,
+			Waiting:   time.Since(conversation.LastActivityAt).Round(time.Minute).String(),
+			Assigned:  conversation.AssigneeID != nil,
+			Link:      s.conversationLink(conversation.ID.String()),
+		})
+	}
+
+	subject, text, err := renderEmail(account.Locale, TypeDigest, map[string]interface{}{
+		"Name":    displayName(&user),
+		"Account": account.Name,
+		"Total":   len(conversations),
+		"Inboxes": inboxes,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	return s.mailer.Send(ctx, mailer.Message{To: user.Email, Subject: subject, Text: text})
+}
@@ -0,0 +1,217 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nakamura/chatwoot-go/internal/mailer"
+	"github.com/nakamura/chatwoot-go/internal/models"
+)
+
+// emailTemplates holds the subject and body of each email by locale. Each body is a
+// text/template; the subject is the first line.
+var emailTemplates = map[string]map[string]string{
+	"en": {
+		TypeAssignment: `{{.Actor}} assigned you conversation #{{.DisplayID}} in {{.Account}}
+Hi {{.Name}},
+
+{{.Actor}} assigned you conversation #{{.DisplayID}} with {{.Contact}} in {{.Inbox}}.
+
+Open it:
+{{.Link}}
+`,
+		TypeMention: `{{.Actor}} mentioned you in conversation #{{.DisplayID}}
+Hi {{.Name}},
+
+{{.Actor}} mentioned you in a private note on conversation #{{.DisplayID}} with {{.Contact}}:
+
+{{.Content}}
+
+Open it:
+{{.Link}}
+`,
+		TypeDigest: `{{.Total}} conversations waiting in {{.Account}}
+Hi {{.Name}},
+
+These conversations are waiting for an agent:
+{{range .Inboxes}}
+{{.Name}}
+{{range .Conversations}}  #{{.DisplayID}} {{.Contact}} ({{.Waiting}}){{if not .Assigned}}, unassigned{{end}}
+    {{.Link}}
+{{end}}{{end}}
+You get this digest because you turned it on in your notification settings.
+`,
+	},
+	"pt_BR": {
+		TypeAssignment: `{{.Actor}} atribuiu a você a conversa #{{.DisplayID}} em {{.Account}}
+Olá {{.Name}},
+
+{{.Actor}} atribuiu a você a conversa #{{.DisplayID}} com {{.Contact}} em {{.Inbox}}.
+
+Abrir a conversa:
+{{.Link}}
+`,
+		TypeMention: `{{.Actor}} mencionou você na conversa #{{.DisplayID}}
+Olá {{.Name}},
+
+{{.Actor}} mencionou você em uma nota privada na conversa #{{.DisplayID}} com {{.Contact}}:
+
+{{.Content}}
+
+Abrir a conversa:
+{{.Link}}
+`,
+		TypeDigest: `{{.Total}} conversas aguardando em {{.Account}}
+Olá {{.Name}},
+
+Estas conversas estão aguardando um agente:
+{{range .Inboxes}}
+{{.Name}}
+{{range .Conversations}}  #{{.DisplayID}} {{.Contact}} ({{.Waiting}}){{if not .Assigned}}, sem responsável{{end}}
+    {{.Link}}
+{{end}}{{end}}
+Você recebe este resumo porque o ativou nas suas preferências de notificação.
+`,
+	},
+	"es": {
+		TypeAssignment: `{{.Actor}} te asignó la conversación #{{.DisplayID}} en {{.Account}}
+Hola {{.Name}},
+
+{{.Actor}} te asignó la conversación #{{.DisplayID}} con {{.Contact}} en {{.Inbox}}.
+
+Abrir la conversación:
+{{.Link}}
+`,
+		TypeMention: `{{.Actor}} te mencionó en la conversación #{{.DisplayID}}
+Hola {{.Name}},
+
+{{.Actor}} te mencionó en una nota privada de la conversación #{{.DisplayID}} con {{.Contact}}:
+
+{{.Content}}
+
+Abrir la conversación:
+{{.Link}}
+`,
+		TypeDigest: `{{.Total}} conversaciones en espera en {{.Account}}
+Hola {{.Name}},
+
+Estas conversaciones están esperando a un agente:
+{{range .Inboxes}}
+{{.Name}}
+{{range .Conversations}}  #{{.DisplayID}} {{.Contact}} ({{.Waiting}}){{if not .Assigned}}, sin asignar{{end}}
+    {{.Link}}
+{{end}}{{end}}
+Recibes este resumen porque lo activaste en tus preferencias de notificación.
+`,
+	},
+}
+
+// emailLocale picks the templates for an account locale: an exact match, then the
+// language (pt -> pt_BR), then English
+func emailLocale(locale string) string {
+	locale = strings.ReplaceAll(locale, "-", "_")
+	if _, ok := emailTemplates[locale]; ok {
+		return locale
+	}
+	language := strings.SplitN(locale, "_", 2)[0]
+	for name := range emailTemplates {
+		if strings.SplitN(name, "_", 2)[0] == language {
+			return name
+		}
+	}
+	return "en"
+}
+
+// renderEmail renders an email template in the locale of an account
+func renderEmail(locale, emailType string, data interface{}) (subject, text string, err error) {
+	tmpl, err := template.New(emailType).Parse(emailTemplates[emailLocale(locale)][emailType])
+	if err != nil {
+		return "", "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", "", err
+	}
+	subject, text, _ = strings.Cut(b.String(), "\n")
+	return subject, text, nil
+}
+
+// email sends a notification by email, unless its user is online and sees it in the app
+func (s *Service) email(notification models.Notification) {
+	if s.mailer == nil || notification.ConversationID == nil {
+		return
+	}
+
+	var user models.User
+	if err := s.db.First(&user, "id = ?", notification.UserID).Error; err != nil || user.Online {
+		return
+	}
+	var account models.Account
+	if err := s.db.First(&account, "id = ?", notification.AccountID).Error; err != nil {
+		return
+	}
+	var conversation models.Conversation
+	if err := s.db.Preload("Inbox").Preload("Contact").First(&conversation, "id = ?", *notification.ConversationID).Error; err != nil {
+		return
+	}
+
+	actor := "Chatwoot"
+	if notification.ActorID != nil {
+		var actorUser models.User
+		if s.db.Select("name", "display_name").First(&actorUser, "id = ?", *notification.ActorID).Error == nil {
+			actor = displayName(&actorUser)
+		}
+	}
+	var content string
+	if notification.MessageID != nil {
+		var message models.Message
+		if s.db.Select("content").First(&message, "id = ?", *notification.MessageID).Error == nil {
+			content = truncate(message.Content, 500)
+		}
+	}
+
+	subject, text, err := renderEmail(account.Locale, notification.NotificationType, map[string]interface{}{
+		"Name":      displayName(&user),
+		"Actor":     actor,
+		"Account":   account.Name,
+		"Inbox":     conversation.Inbox.Name,
+		"Contact":   conversation.Contact.Name,
+		"DisplayID": conversation.DisplayID,
+		"Content":   content,
+		"Link":      s.conversationLink(conversation.ID.String()),
+	})
+	if err != nil {
+		log.Printf("Failed to render %s email: %v", notification.NotificationType, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := s.mailer.Send(ctx, mailer.Message{To: user.Email, Subject: subject, Text: text}); err != nil {
+		log.Printf("Failed to send %s email to user %s: %v", notification.NotificationType, user.ID, err)
+	}
+}
+
+func (s *Service) conversationLink(conversationID string) string {
+	return fmt.Sprintf("%s/conversations/%s", s.frontendURL, conversationID)
+}
+
+func displayName(user *models.User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Name
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "…"
+}
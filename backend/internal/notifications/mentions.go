@@ -0,0 +1,105 @@
+package notifications
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Member is a member of an account, with their role in it
+type Member struct {
+	UserID uuid.UUID
+	Role   string
+}
+
+var (
+	// mentionLink is a Chatwoot mention: [@Name](mention://user/<id>/Name)
+	mentionLink = regexp.MustCompile(`(?:\[[^\]]*\]\()?mention://user/([0-9a-fA-F-]{36})[^)\s]*\)?`)
+	// mentionHandle is an @handle not preceded by a word character (e.g. in an email)
+	mentionHandle = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([\p{L}\p{N}_.-]+)`)
+)
+
+// Mentions returns the members of an account mentioned in content, either as Chatwoot
+// mention links or as @handles (see mentioned)
+func Mentions(db *gorm.DB, accountID uuid.UUID, content string) []Member {
+	if !mentionLink.MatchString(content) && !mentionHandle.MatchString(content) {
+		return nil
+	}
+
+	var members []mentionCandidate
+	db.Table("users").
+		Select("users.id, users.name, users.display_name, users.email, account_users.role").
+		Joins("JOIN account_users ON account_users.user_id = users.id").
+		Where("account_users.account_id = ? AND users.deleted_at IS NULL", accountID).
+		Scan(&members)
+
+	return mentioned(members, content)
+}
+
+// mentionCandidate is a member of the account a note was written in
+type mentionCandidate struct {
+	ID          uuid.UUID
+	Name        string
+	DisplayName string
+	Email       string
+	Role        string
+}
+
+// mentioned returns the members mentioned in content. A handle matches the local part
+// of the email, the name or display name without spaces, or a first name that only one
+// member has (case insensitive).
+func mentioned(members []mentionCandidate, content string) []Member {
+	links := mentionLink.FindAllStringSubmatch(content, -1)
+	// The label of a link is not a handle of its own
+	handles := mentionHandle.FindAllStringSubmatch(mentionLink.ReplaceAllString(content, " "), -1)
+
+	byHandle := make(map[string][]int)
+	firstNames := make(map[string][]int)
+	for i, member := range members {
+		seen := make(map[string]bool)
+		for _, handle := range []string{
+			strings.SplitN(member.Email, "@", 2)[0],
+			strings.Join(strings.Fields(member.Name), ""),
+			strings.Join(strings.Fields(member.DisplayName), ""),
+		} {
+			handle = strings.ToLower(handle)
+			if handle != "" && !seen[handle] {
+				seen[handle] = true
+				byHandle[handle] = append(byHandle[handle], i)
+			}
+		}
+		if fields := strings.Fields(member.Name); len(fields) > 0 {
+			first := strings.ToLower(fields[0])
+			firstNames[first] = append(firstNames[first], i)
+		}
+	}
+
+	found := make(map[int]bool)
+	for _, link := range links {
+		for i, member := range members {
+			if strings.EqualFold(member.ID.String(), link[1]) {
+				found[i] = true
+			}
+		}
+	}
+	for _, match := range handles {
+		handle := strings.ToLower(strings.TrimRight(match[1], ".-_"))
+		matches := byHandle[handle]
+		if len(matches) == 0 && len(firstNames[handle]) == 1 {
+			matches = firstNames[handle]
+		}
+		for _, i := range matches {
+			found[i] = true
+		}
+	}
+
+	var result []Member
+	for i, member := range members {
+		if found[i] {
+			result = append(result, Member{UserID: member.ID, Role: member.Role})
+		}
+	}
+	return result
+}
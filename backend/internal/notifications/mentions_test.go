@@ -0,0 +1,48 @@
+package notifications
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMentioned(t *testing.T) {
+	ana := mentionCandidate{ID: uuid.New(), Name: "Ana Souza", DisplayName: "Aninha", Email: "ana.souza@example.com", Role: "agent"}
+	bruno := mentionCandidate{ID: uuid.New(), Name: "Bruno Lima", Email: "bruno@example.com", Role: "supervisor"}
+	brunoCosta := mentionCandidate{ID: uuid.New(), Name: "Bruno Costa", Email: "bcosta@example.com", Role: "agent"}
+	jose := mentionCandidate{ID: uuid.New(), Name: "José", Email: "jose@example.com", Role: "administrator"}
+	members := []mentionCandidate{ana, bruno, brunoCosta, jose}
+
+	member := func(c mentionCandidate) Member { return Member{UserID: c.ID, Role: c.Role} }
+
+	tests := []struct {
+		name    string
+		content string
+		want    []Member
+	}{
+		{"no mention", "Cliente pediu reembolso", nil},
+		{"email local part", "@ana.souza pode ver?", []Member{member(ana)}},
+		{"name without spaces", "@AnaSouza pode ver?", []Member{member(ana)}},
+		{"display name", "@aninha", []Member{member(ana)}},
+		{"unique first name", "@ana", []Member{member(ana)}},
+		{"shared first name", "@Bruno", []Member{member(bruno)}},
+		{"accented handle", "fala com @josé", []Member{member(jose)}},
+		{"trailing punctuation", "Obrigado, @bcosta.", []Member{member(brunoCosta)}},
+		{"several", "@ana e @BrunoLima", []Member{member(ana), member(bruno)}},
+		{"repeated", "@ana @ana.souza @Aninha", []Member{member(ana)}},
+		{"inside an email", "escreva para contato@ana", nil},
+		{"unknown handle", "@fulano", nil},
+		{"link", "[@Bruno Costa](mention://user/" + brunoCosta.ID.String() + "/Bruno%20Costa) veja", []Member{member(brunoCosta)}},
+		{"link and handle", "[@Bruno Lima](mention://user/" + bruno.ID.String() + "/Bruno%20Lima) e @ana", []Member{member(ana), member(bruno)}},
+		{"link of someone else", "[@X](mention://user/" + uuid.New().String() + "/X)", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mentioned(members, tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mentioned(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
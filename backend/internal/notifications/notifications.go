@@ -0,0 +1,175 @@
+// Package notifications creates the in-app notifications of agents, pushes them to
+// their open sessions and emails the agents who are away.
+package notifications
+
+import (
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/config"
+	"github.com/nakamura/chatwoot-go/internal/mailer"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/websocket"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification types
+const (
+	// TypeAssignment is sent to the agent a conversation is assigned to
+	TypeAssignment = "conversation_assignment"
+	// TypeMention is sent to the agents mentioned in a private note
+	TypeMention = "conversation_mention"
+	// TypeCreation is sent to the members of an inbox when a conversation starts in it
+	TypeCreation = "conversation_creation"
+	// TypeSLABreach is sent when a conversation misses an SLA target
+	TypeSLABreach = "sla_breach"
+)
+
+// TypeDigest is the daily email digest of unattended conversations
+const TypeDigest = "daily_digest"
+
+// Types lists the notification types users can turn on and off
+var Types = []string{TypeAssignment, TypeMention, TypeCreation, TypeSLABreach}
+
+// EmailTypes lists the emails users can turn on and off, with their default
+var EmailTypes = map[string]bool{
+	TypeAssignment: true,
+	TypeMention:    true,
+	TypeDigest:     false,
+}
+
+// Service stores notifications, delivers them in real time and by email
+type Service struct {
+	db          *gorm.DB
+	wsHub       *websocket.Hub
+	mailer      mailer.Mailer
+	frontendURL string
+	digestHour  int
+}
+
+// New creates a notification service
+func New(db *gorm.DB, wsHub *websocket.Hub, mail mailer.Mailer, cfg *config.Config) *Service {
+	return &Service{
+		db:          db,
+		wsHub:       wsHub,
+		mailer:      mail,
+		frontendURL: strings.TrimRight(cfg.FrontendURL, "/"),
+		digestHour:  cfg.DigestHour,
+	}
+}
+
+// Notify stores a notification and pushes it to its user as notification.created, and
+// emails it when the user is away. Nobody is notified of their own actions, nor of
+// types they turned off.
+func (s *Service) Notify(notification *models.Notification) error {
+	if notification.ActorID != nil && *notification.ActorID == notification.UserID {
+		return nil
+	}
+
+	preferences := s.Preferences(notification.AccountID, notification.UserID)
+	if preferences.Email[notification.NotificationType] {
+		go s.email(*notification)
+	}
+	if !preferences.InApp[notification.NotificationType] {
+		return nil
+	}
+	if err := s.db.Create(notification).Error; err != nil {
+		return err
+	}
+
+	if s.wsHub != nil {
+		s.db.Preload("Actor").First(notification, "id = ?", notification.ID)
+		s.wsHub.BroadcastToUser(notification.UserID, "notification.created", notification)
+	}
+	return nil
+}
+
+// ConversationAssigned notifies the agent a conversation was assigned to
+func (s *Service) ConversationAssigned(conversation *models.Conversation, assigneeID uuid.UUID, actorID *uuid.UUID) {
+	s.notifyAll(conversation, []uuid.UUID{assigneeID}, TypeAssignment, actorID, nil)
+}
+
+// ConversationCreated notifies the members of the inbox a conversation started in
+func (s *Service) ConversationCreated(conversation *models.Conversation, actorID *uuid.UUID) {
+	s.notifyAll(conversation, s.inboxMembers(conversation), TypeCreation, actorID, nil)
+}
+
+// SLABreached notifies the assignee of a conversation that missed an SLA target, or
+// the members of its inbox while it is unassigned
+func (s *Service) SLABreached(conversation *models.Conversation, metric string) {
+	userIDs := s.inboxMembers(conversation)
+	if conversation.AssigneeID != nil {
+		userIDs = []uuid.UUID{*conversation.AssigneeID}
+	}
+	s.notifyAll(conversation, userIDs, TypeSLABreach, nil, models.JSONB{"metric": metric})
+}
+
+func (s *Service) notifyAll(conversation *models.Conversation, userIDs []uuid.UUID, notificationType string, actorID *uuid.UUID, data models.JSONB) {
+	for _, userID := range userIDs {
+		err := s.Notify(&models.Notification{
+			AccountID:        conversation.AccountID,
+			UserID:           userID,
+			NotificationType: notificationType,
+			ActorID:          actorID,
+			ConversationID:   &conversation.ID,
+			Data:             data,
+		})
+		if err != nil {
+			log.Printf("Failed to notify user %s of %s: %v", userID, notificationType, err)
+		}
+	}
+}
+
+// inboxMembers lists the members of the inbox of a conversation who still belong to its account
+func (s *Service) inboxMembers(conversation *models.Conversation) []uuid.UUID {
+	var userIDs []uuid.UUID
+	s.db.Model(&models.InboxMember{}).
+		Joins("JOIN account_users ON account_users.user_id = inbox_members.user_id AND account_users.account_id = ?", conversation.AccountID).
+		Where("inbox_members.inbox_id = ?", conversation.InboxID).
+		Pluck("inbox_members.user_id", &userIDs)
+	return userIDs
+}
+
+// Preferences are the notification types a user gets, by channel
+type Preferences struct {
+	InApp map[string]bool `json:"in_app"`
+	Email map[string]bool `json:"email"`
+}
+
+// Preferences returns the notification preferences of a user in an account
+func (s *Service) Preferences(accountID, userID uuid.UUID) Preferences {
+	var setting models.NotificationSetting
+	s.db.Where("account_id = ? AND user_id = ?", accountID, userID).Limit(1).Find(&setting)
+
+	preferences := Preferences{
+		InApp: make(map[string]bool, len(Types)),
+		Email: make(map[string]bool, len(EmailTypes)),
+	}
+	for _, notificationType := range Types {
+		enabled, ok := setting.InApp[notificationType].(bool)
+		preferences.InApp[notificationType] = !ok || enabled
+	}
+	for emailType, byDefault := range EmailTypes {
+		enabled, ok := setting.Email[emailType].(bool)
+		preferences.Email[emailType] = enabled || !ok && byDefault
+	}
+	return preferences
+}
+
+// SetPreferences stores the notification preferences of a user in an account
+func (s *Service) SetPreferences(accountID, userID uuid.UUID, preferences Preferences) error {
+	inApp, email := models.JSONB{}, models.JSONB{}
+	for notificationType, enabled := range preferences.InApp {
+		inApp[notificationType] = enabled
+	}
+	for emailType, enabled := range preferences.Email {
+		email[emailType] = enabled
+	}
+	setting := models.NotificationSetting{AccountID: accountID, UserID: userID, InApp: inApp, Email: email}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "updated_at"}),
+	}).Create(&setting).Error
+}
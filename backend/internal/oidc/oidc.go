@@ -0,0 +1,403 @@
+// Package oidc implements the parts of OpenID Connect needed for single sign-on:
+// discovery, the authorization code flow with PKCE and ID token verification.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// cacheTTL is how long discovery documents and signing keys are reused
+const cacheTTL = time.Hour
+
+// Metadata is the subset of the provider's discovery document we use
+type Metadata struct {
+	Issuer                   string   `json:"issuer"`
+	AuthorizationEndpoint    string   `json:"authorization_endpoint"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// Config identifies our application at the provider
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+}
+
+// Claims are the claims of a verified ID token
+type Claims map[string]interface{}
+
+// String returns a string claim, or "" when missing
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim that may be a string or a list of strings (e.g. groups)
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Bool returns a boolean claim and whether it was present
+func (c Claims) Bool(name string) (bool, bool) {
+	b, ok := c[name].(bool)
+	return b, ok
+}
+
+type cachedMetadata struct {
+	metadata  *Metadata
+	fetchedAt time.Time
+}
+
+type cachedKeys struct {
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// ErrUnsafeURL is returned for provider URLs that are not https or that point
+// into the server's own network
+var ErrUnsafeURL = errors.New("identity provider URLs must be public https addresses")
+
+// Client talks to OpenID providers. It caches discovery documents and signing keys.
+type Client struct {
+	http          *http.Client
+	allowInsecure bool
+	mu            sync.Mutex
+	metadata      map[string]cachedMetadata
+	keys          map[string]cachedKeys
+}
+
+// NewClient returns a client that only reaches public https endpoints and never
+// follows redirects. allowInsecure lifts both checks, for local development only.
+func NewClient(allowInsecure bool) *Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowInsecure {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+
+	return &Client{
+		http: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowInsecure: allowInsecure,
+		metadata:      make(map[string]cachedMetadata),
+		keys:          make(map[string]cachedKeys),
+	}
+}
+
+// CheckURL rejects provider URLs that are not https or name a non-public address
+func (cl *Client) CheckURL(rawURL string) error {
+	if cl.allowInsecure {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return ErrUnsafeURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !publicIP(ip) {
+		return ErrUnsafeURL
+	}
+	if host := strings.ToLower(u.Hostname()); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrUnsafeURL
+	}
+	return nil
+}
+
+// refusePrivateAddress checks the resolved address of every connection, so that a
+// public host name cannot resolve into the internal network
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrUnsafeURL, host)
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// Discover fetches the discovery document of an issuer
+func (cl *Client) Discover(ctx context.Context, issuer string) (*Metadata, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	if err := cl.CheckURL(issuer); err != nil {
+		return nil, err
+	}
+
+	cl.mu.Lock()
+	cached, ok := cl.metadata[issuer]
+	cl.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < cacheTTL {
+		return cached.metadata, nil
+	}
+
+	var metadata Metadata
+	if err := cl.getJSON(ctx, issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery returned issuer %q, expected %q", metadata.Issuer, issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+	for _, endpoint := range []string{metadata.AuthorizationEndpoint, metadata.TokenEndpoint, metadata.JWKSURI} {
+		if err := cl.CheckURL(endpoint); err != nil {
+			return nil, fmt.Errorf("discovery document: %w", err)
+		}
+	}
+
+	cl.mu.Lock()
+	cl.metadata[issuer] = cachedMetadata{metadata: &metadata, fetchedAt: time.Now()}
+	cl.mu.Unlock()
+	return &metadata, nil
+}
+
+// NewCodeVerifier returns a random PKCE code verifier
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge of a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL that starts the authorization code flow at the provider
+func AuthCodeURL(metadata *Metadata, cfg Config, state, nonce, codeVerifier string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURI},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange trades an authorization code for tokens and returns the raw ID token
+func (cl *Client) Exchange(ctx context.Context, metadata *Metadata, cfg Config, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURI},
+		"code_verifier": {codeVerifier},
+		"client_id":     {cfg.ClientID},
+	}
+
+	// client_secret_basic is the default; use client_secret_post when it is the only method offered
+	postSecret := len(metadata.TokenEndpointAuthMethods) > 0
+	for _, method := range metadata.TokenEndpointAuthMethods {
+		if method == "client_secret_basic" {
+			postSecret = false
+		}
+	}
+	if postSecret && cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !postSecret && cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := cl.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token endpoint returned no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of an ID token
+func (cl *Client) Verify(ctx context.Context, metadata *Metadata, clientID, rawIDToken, nonce string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return cl.signingKey(ctx, metadata.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	// With several audiences the token must have been issued to us
+	if azp, ok := claims["azp"].(string); ok && azp != clientID {
+		return nil, errors.New("token was issued to another client")
+	}
+
+	return Claims(claims), nil
+}
+
+// signingKey returns the provider key with the given ID, refreshing the key set once
+// when the ID is unknown (key rotation)
+func (cl *Client) signingKey(ctx context.Context, jwksURI, kid string) (*rsa.PublicKey, error) {
+	cl.mu.Lock()
+	cached, ok := cl.keys[jwksURI]
+	cl.mu.Unlock()
+
+	if !ok || time.Since(cached.fetchedAt) >= cacheTTL || findKey(cached.keys, kid) == nil {
+		keys, err := cl.fetchKeys(ctx, jwksURI)
+		if err != nil {
+			return nil, err
+		}
+		cached = cachedKeys{keys: keys, fetchedAt: time.Now()}
+		cl.mu.Lock()
+		cl.keys[jwksURI] = cached
+		cl.mu.Unlock()
+	}
+
+	if key := findKey(cached.keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// findKey looks a key up by ID; without an ID, a single key is used
+func findKey(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[kid]
+}
+
+// fetchKeys downloads the RSA signing keys of a provider
+func (cl *Client) fetchKeys(ctx context.Context, jwksURI string) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := cl.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func (cl *Client) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := cl.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
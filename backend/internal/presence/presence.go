@@ -0,0 +1,270 @@
+// Package presence tracks which agents are connected and keeps their availability
+// in sync: agents who disconnect go offline once a grace period has passed, and come
+// back with their previous availability when they reconnect.
+package presence
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/websocket"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// Grace is how long an agent may stay disconnected (a reload, a flaky network)
+	// before going offline
+	Grace = time.Minute
+	// connectionTTL is how long a connection counts as live without being refreshed,
+	// so that connections of a crashed node expire by themselves
+	connectionTTL = 90 * time.Second
+)
+
+// Store records until when each connection of a user was live, shared by all nodes
+type Store interface {
+	// Touch records that a connection is live until the given time
+	Touch(ctx context.Context, userID, connectionID uuid.UUID, until time.Time) error
+	// LastSeen returns the latest time a connection of the user was live, zero if none
+	LastSeen(ctx context.Context, userID uuid.UUID) (time.Time, error)
+}
+
+// NewStore returns a Redis store, or a memory store when there is no Redis client
+func NewStore(client *redis.Client) Store {
+	if client == nil {
+		return NewMemoryStore()
+	}
+	return NewRedisStore(client)
+}
+
+// MemoryStore keeps the connections of this node in memory
+type MemoryStore struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]map[uuid.UUID]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: make(map[uuid.UUID]map[uuid.UUID]time.Time)}
+}
+
+func (s *MemoryStore) Touch(ctx context.Context, userID, connectionID uuid.UUID, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	connections, ok := s.users[userID]
+	if !ok {
+		connections = make(map[uuid.UUID]time.Time)
+		s.users[userID] = connections
+	}
+	connections[connectionID] = until
+
+	// Connections closed long ago no longer matter
+	for id, seen := range connections {
+		if time.Since(seen) > 24*time.Hour {
+			delete(connections, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) LastSeen(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var last time.Time
+	for _, seen := range s.users[userID] {
+		if seen.After(last) {
+			last = seen
+		}
+	}
+	return last, nil
+}
+
+// RedisStore keeps a sorted set per user: connection IDs scored by the time they are
+// live until
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func presenceKey(userID uuid.UUID) string {
+	return "chatwoot:presence:" + userID.String()
+}
+
+func (s *RedisStore) Touch(ctx context.Context, userID, connectionID uuid.UUID, until time.Time) error {
+	key := presenceKey(userID)
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(until.Unix()), Member: connectionID.String()})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(time.Now().Add(-24*time.Hour).Unix(), 10))
+	pipe.Expire(ctx, key, 24*time.Hour)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) LastSeen(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	latest, err := s.client.ZRevRangeWithScores(ctx, presenceKey(userID), 0, 0).Result()
+	if err != nil || len(latest) == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(latest[0].Score), 0), nil
+}
+
+// Tracker follows the WebSocket connections of this node and updates the presence
+// of their users
+type Tracker struct {
+	db    *gorm.DB
+	wsHub *websocket.Hub
+	store Store
+
+	mu          sync.Mutex
+	connections map[uuid.UUID]uuid.UUID // connection ID -> user ID
+}
+
+// NewTracker creates a presence tracker
+func NewTracker(db *gorm.DB, wsHub *websocket.Hub, store Store) *Tracker {
+	return &Tracker{db: db, wsHub: wsHub, store: store, connections: make(map[uuid.UUID]uuid.UUID)}
+}
+
+// Connected records a new connection and brings its user online. It must return
+// before Disconnected is called for the connection, or the connection would be
+// recorded as live again after it closed.
+func (t *Tracker) Connected(userID, connectionID uuid.UUID) {
+	t.mu.Lock()
+	t.connections[connectionID] = userID
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := t.store.Touch(ctx, userID, connectionID, time.Now().Add(connectionTTL)); err != nil {
+		log.Printf("Presence: failed to record connection of user %s: %v", userID, err)
+	}
+
+	// Only the first connection changes anything; reconnecting within the grace
+	// period never went offline
+	result := t.db.Model(&models.User{}).Where("id = ? AND online = ?", userID, false).Update("online", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	// Restore the availability the user had before going offline automatically
+	t.db.Model(&models.User{}).Where("id = ? AND auto_away_from <> ''", userID).Updates(map[string]interface{}{
+		"availability":   gorm.Expr("auto_away_from"),
+		"auto_away_from": "",
+	})
+	Announce(t.db, t.wsHub, userID)
+}
+
+// Disconnected records that a connection closed. The user goes offline once none of
+// their connections was live for the grace period (see Run).
+func (t *Tracker) Disconnected(userID, connectionID uuid.UUID) {
+	t.mu.Lock()
+	delete(t.connections, connectionID)
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := t.store.Touch(ctx, userID, connectionID, time.Now()); err != nil {
+		log.Printf("Presence: failed to record disconnection of user %s: %v", userID, err)
+	}
+}
+
+// Run refreshes the connections of this node and marks offline the users whose grace
+// period is over, every interval (which must be shorter than connectionTTL)
+func (t *Tracker) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.refresh()
+		t.sweep()
+	}
+}
+
+func (t *Tracker) refresh() {
+	t.mu.Lock()
+	connections := make(map[uuid.UUID]uuid.UUID, len(t.connections))
+	for connectionID, userID := range t.connections {
+		connections[connectionID] = userID
+	}
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	until := time.Now().Add(connectionTTL)
+	for connectionID, userID := range connections {
+		if err := t.store.Touch(ctx, userID, connectionID, until); err != nil {
+			log.Printf("Presence: failed to refresh connection of user %s: %v", userID, err)
+			return
+		}
+	}
+}
+
+// sweep takes offline the users without a live connection for the grace period. Any
+// node may do it; the conditional update makes sure only one announces it.
+func (t *Tracker) sweep() {
+	var users []models.User
+	if err := t.db.Select("id").Where("online = ?", true).Find(&users).Error; err != nil {
+		log.Printf("Presence: failed to load online users: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, user := range users {
+		lastSeen, err := t.store.LastSeen(ctx, user.ID)
+		if err != nil {
+			log.Printf("Presence: failed to read presence of user %s: %v", user.ID, err)
+			return
+		}
+		if time.Since(lastSeen) < Grace {
+			continue
+		}
+
+		updates := map[string]interface{}{"online": false}
+		if !lastSeen.IsZero() {
+			updates["last_seen_at"] = lastSeen
+		}
+		result := t.db.Model(&models.User{}).Where("id = ? AND online = ?", user.ID, true).Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		t.db.Model(&models.User{}).
+			Where("id = ? AND auto_offline = ? AND availability <> ?", user.ID, true, "offline").
+			Updates(map[string]interface{}{
+				"auto_away_from": gorm.Expr("availability"),
+				"availability":   "offline",
+			})
+		Announce(t.db, t.wsHub, user.ID)
+	}
+}
+
+// Announce broadcasts the presence of a user to every account they belong to
+func Announce(db *gorm.DB, wsHub *websocket.Hub, userID uuid.UUID) {
+	if wsHub == nil {
+		return
+	}
+
+	var user models.User
+	if err := db.Select("id", "availability", "online", "last_seen_at").First(&user, "id = ?", userID).Error; err != nil {
+		return
+	}
+	var accountIDs []uuid.UUID
+	db.Model(&models.AccountUser{}).Where("user_id = ?", userID).Pluck("account_id", &accountIDs)
+
+	payload := map[string]interface{}{
+		"user_id":      user.ID,
+		"availability": user.Availability,
+		"online":       user.Online,
+		"last_seen_at": user.LastSeenAt,
+	}
+	for _, accountID := range accountIDs {
+		wsHub.BroadcastToRoom(accountID, websocket.AccountRoom(accountID), "presence.update", payload)
+	}
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limiter counts attempts per key
+type Limiter interface {
+	// Allow records an attempt for key and reports whether it is within limit
+	// attempts per window.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+// New returns a Redis sliding-window limiter that falls back to memory when Redis
+// fails, or a memory limiter when there is no Redis client
+func New(client *redis.Client) Limiter {
+	if client == nil {
+		log.Println("⚠️ Rate limiting uses in-memory counters (Redis unavailable)")
+		return NewMemoryLimiter()
+	}
+	return &fallbackLimiter{primary: NewRedisLimiter(client), fallback: NewMemoryLimiter()}
+}
+
+// RedisLimiter is a sliding-window limiter backed by Redis sorted sets: every attempt
+// is a member scored by its timestamp, and attempts older than the window are dropped.
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisLimiter creates a limiter storing its attempts in Redis
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+// Allow records an attempt and counts the attempts of the last window
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	now := time.Now().UnixNano()
+	redisKey := "chatwoot:ratelimit:" + key
+
+	pipe := l.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, redisKey, "0", fmt.Sprint(now-int64(window)))
+	pipe.ZAdd(ctx, redisKey, redis.Z{Score: float64(now), Member: fmt.Sprintf("%d-%d", now, rand.Int63())})
+	count := pipe.ZCard(ctx, redisKey)
+	pipe.PExpire(ctx, redisKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return count.Val() <= int64(limit), nil
+}
+
+// MemoryLimiter is a sliding-window limiter kept in process memory. Limits are per
+// server instance, so it is meant as a fallback for Redis.
+type MemoryLimiter struct {
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+	calls    int
+}
+
+// NewMemoryLimiter creates an in-memory limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{attempts: make(map[string][]time.Time)}
+}
+
+// Allow records an attempt and counts the attempts of the last window
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	attempts := append(prune(l.attempts[key], now.Add(-window)), now)
+	l.attempts[key] = attempts
+
+	// Drop idle keys from time to time so the map does not grow forever
+	l.calls++
+	if l.calls%1000 == 0 {
+		for k, v := range l.attempts {
+			if len(v) == 0 || now.Sub(v[len(v)-1]) > 24*time.Hour {
+				delete(l.attempts, k)
+			}
+		}
+	}
+
+	return len(attempts) <= limit, nil
+}
+
+// prune drops the attempts made before since
+func prune(attempts []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(attempts) && attempts[i].Before(since) {
+		i++
+	}
+	return attempts[i:]
+}
+
+// fallbackLimiter uses primary and switches to fallback for the calls where primary fails
+type fallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+}
+
+func (l *fallbackLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	allowed, err := l.primary.Allow(ctx, key, limit, window)
+	if err != nil {
+		log.Printf("Rate limiter: Redis error, using in-memory counters: %v", err)
+		return l.fallback.Allow(ctx, key, limit, window)
+	}
+	return allowed, nil
+}
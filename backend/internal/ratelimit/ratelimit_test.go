@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewMemoryLimiter()
+
+	tests := []struct {
+		name  string
+		key   string
+		limit int
+		want  []bool
+	}{
+		{"within the limit", "a", 3, []bool{true, true, true}},
+		{"over the limit", "b", 2, []bool{true, true, false, false}},
+		{"keys count apart", "c", 1, []bool{true, false}},
+		{"zero limit", "d", 0, []bool{false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				got, err := limiter.Allow(ctx, tt.key, tt.limit, time.Hour)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("attempt %d allowed = %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryLimiterWindowSlides(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewMemoryLimiter()
+	window := 50 * time.Millisecond
+
+	for i := 0; i < 2; i++ {
+		limiter.Allow(ctx, "key", 2, window)
+	}
+	if allowed, _ := limiter.Allow(ctx, "key", 2, window); allowed {
+		t.Fatal("third attempt within the window was allowed")
+	}
+
+	time.Sleep(2 * window)
+	if allowed, _ := limiter.Allow(ctx, "key", 2, window); !allowed {
+		t.Error("attempt after the window was denied")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	base := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	at := func(seconds ...int) []time.Time {
+		times := []time.Time{}
+		for _, s := range seconds {
+			times = append(times, base.Add(time.Duration(s)*time.Second))
+		}
+		return times
+	}
+
+	tests := []struct {
+		name     string
+		attempts []time.Time
+		since    time.Time
+		want     []time.Time
+	}{
+		{"none", at(), base, at()},
+		{"all recent", at(1, 2), base, at(1, 2)},
+		{"all old", at(-2, -1), base, at()},
+		{"some old", at(-1, 0, 1), base, at(0, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prune(tt.attempts, tt.since); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prune = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type stubLimiter struct {
+	allowed bool
+	err     error
+	calls   int
+}
+
+func (l *stubLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	l.calls++
+	return l.allowed, l.err
+}
+
+func TestFallbackLimiter(t *testing.T) {
+	tests := []struct {
+		name          string
+		primary       *stubLimiter
+		want          bool
+		wantFallbacks int
+	}{
+		{"primary allows", &stubLimiter{allowed: true}, true, 0},
+		{"primary denies", &stubLimiter{allowed: false}, false, 0},
+		{"primary fails", &stubLimiter{err: errors.New("connection refused")}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := &stubLimiter{allowed: true}
+			limiter := &fallbackLimiter{primary: tt.primary, fallback: fallback}
+
+			got, err := limiter.Allow(context.Background(), "key", 1, time.Minute)
+			if err != nil {
+				t.Fatalf("Allow returned %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("allowed = %v, want %v", got, tt.want)
+			}
+			if fallback.calls != tt.wantFallbacks {
+				t.Errorf("fallback called %d times, want %d", fallback.calls, tt.wantFallbacks)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	ws "github.com/nakamura/chatwoot-go/internal/websocket"
+)
+
+// Open real-time connections end when the credentials they were opened with stop
+// being valid, or when the user's access to the account changes
+func TestRevocationClosesConnections(t *testing.T) {
+	db := testDB(t)
+	router, cfg := testServer(t, db)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	tn := seedTenant(t, db, "revocation")
+	admin := login(t, router, cfg, tn.admin, tn.account, "administrator")
+
+	// The password change comes last, since the others sign in with the seeded password
+	tests := []struct {
+		name   string
+		revoke func(t *testing.T, agent actor) *httptest.ResponseRecorder
+	}{
+		{"logout", func(t *testing.T, agent actor) *httptest.ResponseRecorder {
+			return agent.do(t, router, http.MethodPost, "/api/v1/auth/logout", nil)
+		}},
+		{"session revoked from another device", func(t *testing.T, agent actor) *httptest.ResponseRecorder {
+			other := login(t, router, cfg, tn.agent, tn.account, "agent")
+			return other.do(t, router, http.MethodDelete, "/api/v1/profile/sessions/"+agent.sessionID.String(), nil)
+		}},
+		{"other sessions revoked", func(t *testing.T, agent actor) *httptest.ResponseRecorder {
+			other := login(t, router, cfg, tn.agent, tn.account, "agent")
+			return other.do(t, router, http.MethodDelete, "/api/v1/profile/sessions", nil)
+		}},
+		{"role change", func(t *testing.T, agent actor) *httptest.ResponseRecorder {
+			return admin.do(t, router, http.MethodPut, "/api/v1/admin/users/"+tn.agent.ID.String()+"/role", gin.H{"role": "supervisor"})
+		}},
+		{"password change", func(t *testing.T, agent actor) *httptest.ResponseRecorder {
+			return agent.do(t, router, http.MethodPut, "/api/v1/profile/password", gin.H{
+				"current_password": testPassword,
+				"new_password":     "a-brand-new-password",
+			})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := login(t, router, cfg, tn.agent, tn.account, "agent")
+			conn := dialCable(t, server, agent)
+			defer conn.Close()
+
+			if w := tt.revoke(t, agent); w.Code != http.StatusOK {
+				t.Fatalf("revoke = %d: %s", w.Code, w.Body)
+			}
+
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			for {
+				_, _, err := conn.ReadMessage()
+				if err == nil {
+					continue
+				}
+				var closeErr *websocket.CloseError
+				if !errors.As(err, &closeErr) || closeErr.Code != ws.CloseRevoked {
+					t.Errorf("connection ended with %v, want close code %d", err, ws.CloseRevoked)
+				}
+				return
+			}
+		})
+	}
+}
+
+// dialCable opens /cable for an actor and waits until the hub serves the connection
+func dialCable(t *testing.T, server *httptest.Server, a actor) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/cable?token=" + a.token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial /cable: %v", err)
+	}
+
+	// A denied subscription answers once the client is registered and its pumps run
+	room := ws.AccountRoom(uuid.New())
+	if err := conn.WriteJSON(ws.Message{Type: "subscribe", Payload: room}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var message ws.Message
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("waiting for the connection: %v", err)
+		}
+		if message.Type == "subscription.denied" {
+			conn.SetReadDeadline(time.Time{})
+			return conn
+		}
+	}
+}
@@ -2,9 +2,15 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/nakamura/chatwoot-go/internal/channels"
 	"github.com/nakamura/chatwoot-go/internal/config"
 	"github.com/nakamura/chatwoot-go/internal/handlers"
+	"github.com/nakamura/chatwoot-go/internal/mailer"
 	"github.com/nakamura/chatwoot-go/internal/middleware"
+	"github.com/nakamura/chatwoot-go/internal/notifications"
+	"github.com/nakamura/chatwoot-go/internal/presence"
+	"github.com/nakamura/chatwoot-go/internal/ratelimit"
+	"github.com/nakamura/chatwoot-go/internal/sla"
 	"github.com/nakamura/chatwoot-go/internal/storage"
 	"github.com/nakamura/chatwoot-go/internal/websocket"
 	"github.com/redis/go-redis/v9"
@@ -17,20 +23,33 @@ func SetupRoutes(
 	db *gorm.DB,
 	redis *redis.Client,
 	wsHub *websocket.Hub,
+	slaService *sla.Service,
+	presenceTracker *presence.Tracker,
+	notifier *notifications.Service,
 	storageService *storage.MinioService,
 	cfg *config.Config,
 ) {
+	// Shared services
+	mail := mailer.New(cfg)
+	limiter := ratelimit.New(redis)
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db, cfg)
-	accountHandler := handlers.NewAccountHandler(db)
-	conversationHandler := handlers.NewConversationHandler(db, wsHub)
-	messageHandler := handlers.NewMessageHandler(db, wsHub)
+	authHandler := handlers.NewAuthHandler(db, wsHub, cfg, mail, limiter)
+	accountHandler := handlers.NewAccountHandler(db, wsHub)
+	conversationHandler := handlers.NewConversationHandler(db, wsHub, slaService, notifier)
 	contactHandler := handlers.NewContactHandler(db)
 	inboxHandler := handlers.NewInboxHandler(db)
 	uploadHandler := handlers.NewUploadHandler(storageService)
-	wsHandler := handlers.NewWebSocketHandler(wsHub, cfg)
+	channelRegistry := channels.NewRegistry(cfg)
+	messageHandler := handlers.NewMessageHandler(db, wsHub, cfg, channelRegistry, notifier)
+	wsHandler := handlers.NewWebSocketHandler(db, wsHub, cfg, channelRegistry, presenceTracker)
 	webhookHandler := handlers.NewWebhookHandler(db)
-	incomingWebhookHandler := handlers.NewIncomingWebhookHandler(db, wsHub)
+	incomingWebhookHandler := handlers.NewIncomingWebhookHandler(db, wsHub, slaService, channelRegistry, notifier)
+	slaHandler := handlers.NewSLAHandler(db)
+	invitationHandler := handlers.NewInvitationHandler(db, cfg, mail)
+	accessTokenHandler := handlers.NewAccessTokenHandler(db)
+	ssoHandler := handlers.NewSSOHandler(db, cfg)
+	notificationHandler := handlers.NewNotificationHandler(db, notifier)
 
 	// Public routes
 	public := router.Group("/api/v1")
@@ -40,52 +59,107 @@ func SetupRoutes(
 		public.POST("/auth/register", authHandler.Register)
 		public.POST("/auth/forgot-password", authHandler.ForgotPassword)
 		public.POST("/auth/reset-password", authHandler.ResetPassword)
+		public.POST("/auth/refresh", authHandler.Refresh)
+		public.POST("/auth/2fa/verify", authHandler.VerifyTwoFactor)
+		public.POST("/auth/unlock", authHandler.Unlock)
+		public.GET("/auth/invitation", invitationHandler.Show)
+		public.POST("/auth/invitation/accept", invitationHandler.Accept)
+		public.POST("/auth/sso/start", ssoHandler.Start)
+		public.POST("/auth/sso/callback", ssoHandler.Callback)
 
 		// Public API (for widget)
 		public.POST("/widget/contacts", contactHandler.CreatePublicContact)
 		public.POST("/widget/conversations", conversationHandler.CreatePublicConversation)
 		public.POST("/widget/messages", messageHandler.CreatePublicMessage)
 
-		// Incoming webhooks (authenticated via API token in header)
+		// Incoming webhooks (authenticated via an API token with the webhooks:ingest scope)
 		// Using wildcard to support both /:instance and /:account_id/:instance patterns without Gin conflicts
 		public.POST("/webhooks/incoming/*pathParam", incomingWebhookHandler.HandleIncoming)
+
+		// Event stream (authenticated like the WebSocket endpoint)
+		public.GET("/events/stream", wsHandler.HandleEventStream)
 	}
 
 	// Protected routes
 	api := router.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware(cfg))
+	api.Use(middleware.AuthMiddleware(cfg, db))
 	{
 		// Storage
 		api.POST("/storage/upload", uploadHandler.Upload)
 
+		// Session
+		api.POST("/auth/switch_account", authHandler.SwitchAccount)
+		api.POST("/auth/logout", authHandler.Logout)
+		api.GET("/profile/sessions", authHandler.ListSessions)
+		api.DELETE("/profile/sessions", authHandler.RevokeOtherSessions)
+		api.DELETE("/profile/sessions/:id", authHandler.RevokeSession)
+
+		// Two-factor authentication
+		api.POST("/profile/2fa/setup", authHandler.SetupTwoFactor)
+		api.POST("/profile/2fa/enable", authHandler.EnableTwoFactor)
+		api.POST("/profile/2fa/disable", authHandler.DisableTwoFactor)
+		api.POST("/profile/2fa/recovery_codes", authHandler.RegenerateRecoveryCodes)
+
 		// Profile
 		api.GET("/profile", authHandler.GetProfile)
 		api.PUT("/profile", authHandler.UpdateProfile)
 		api.PUT("/profile/password", authHandler.ChangePassword)
 		api.PUT("/profile/availability", authHandler.UpdateAvailability)
-		api.POST("/profile/access_token", authHandler.ResetAccessToken)
+		api.GET("/profile/notification_settings", notificationHandler.GetSettings)
+		api.PUT("/profile/notification_settings", notificationHandler.UpdateSettings)
+
+		// Notifications
+		notificationRoutes := api.Group("/notifications")
+		{
+			notificationRoutes.GET("", notificationHandler.List)
+			notificationRoutes.POST("/read_all", notificationHandler.MarkAllRead)
+			notificationRoutes.POST("/:id/read", notificationHandler.MarkRead)
+		}
 
-		// API Tokens (Legacy removed, using User AccessToken)
+		// Personal API tokens
+		api.GET("/profile/access_tokens", accessTokenHandler.List)
+		api.POST("/profile/access_tokens", accessTokenHandler.Create)
+		api.DELETE("/profile/access_tokens/:id", accessTokenHandler.Delete)
 
 		// Accounts
 		accounts := api.Group("/accounts")
 		{
 			accounts.GET("", accountHandler.List)
 			accounts.POST("", accountHandler.Create)
-			accounts.GET("/:id", accountHandler.Get)
-			accounts.PUT("/:id", accountHandler.Update)
-			accounts.DELETE("/:id", accountHandler.Delete)
+			accounts.GET("/:id", middleware.RequireActiveAccount(), accountHandler.Get)
+			accounts.PUT("/:id", middleware.RequireActiveAccount(), middleware.RequireRole("administrator"), accountHandler.Update)
+			accounts.DELETE("/:id", middleware.RequireActiveAccount(), middleware.RequireRole("administrator"), accountHandler.Delete)
 
 			// Account users
-			accounts.GET("/:id/users", accountHandler.ListUsers)
-			accounts.POST("/:id/users", accountHandler.AddUser)
-			accounts.DELETE("/:id/users/:user_id", accountHandler.RemoveUser)
-
-			// Account webhooks
-			accounts.GET("/:id/webhooks", webhookHandler.List)
-			accounts.POST("/:id/webhooks", webhookHandler.Create)
-			accounts.PUT("/:id/webhooks/:webhook_id", webhookHandler.Update)
-			accounts.DELETE("/:id/webhooks/:webhook_id", webhookHandler.Delete)
+			accountUsers := accounts.Group("/:id/users", middleware.RequireActiveAccount())
+			accountUsers.GET("", accountHandler.ListUsers)
+			// Users join an account by accepting an invitation, never without their consent
+			accountUsers.POST("", middleware.RequireRole("administrator"), invitationHandler.Create)
+			accountUsers.DELETE("/:user_id", middleware.RequireRole("administrator"), accountHandler.RemoveUser)
+
+			// Account webhooks: they receive the account's events, private notes included
+			// when asked to, so only administrators manage them
+			accountWebhooks := accounts.Group("/:id/webhooks", middleware.RequireActiveAccount(), middleware.RequireRole("administrator"))
+			accountWebhooks.GET("", webhookHandler.List)
+			accountWebhooks.POST("", webhookHandler.Create)
+			accountWebhooks.PUT("/:webhook_id", webhookHandler.Update)
+			accountWebhooks.DELETE("/:webhook_id", webhookHandler.Delete)
+
+			// Account single sign-on (OIDC)
+			accountSSO := accounts.Group("/:id/sso", middleware.RequireActiveAccount(), middleware.RequireRole("administrator"))
+			accountSSO.GET("", ssoHandler.GetProvider)
+			accountSSO.PUT("", ssoHandler.UpdateProvider)
+			accountSSO.DELETE("", ssoHandler.DeleteProvider)
+		}
+
+		// Invitations
+		invitations := api.Group("/invitations")
+		invitations.Use(middleware.RequireRole("administrator"))
+		{
+			invitations.GET("", invitationHandler.List)
+			invitations.POST("", invitationHandler.Create)
+			invitations.POST("/:id/resend", invitationHandler.Resend)
+			invitations.DELETE("/:id", invitationHandler.Delete)
 		}
 
 		// Conversations
@@ -95,7 +169,7 @@ func SetupRoutes(
 			conversations.POST("", conversationHandler.Create)
 			conversations.GET("/:id", conversationHandler.Get)
 			conversations.PUT("/:id", conversationHandler.Update)
-			conversations.DELETE("/:id", conversationHandler.Delete)
+			conversations.DELETE("/:id", middleware.RequireRole("administrator"), conversationHandler.Delete)
 			conversations.POST("/:id/assign", conversationHandler.Assign)
 			conversations.POST("/:id/resolve", conversationHandler.Resolve)
 			conversations.POST("/:id/reopen", conversationHandler.Reopen)
@@ -131,12 +205,32 @@ func SetupRoutes(
 			inboxes.GET("", inboxHandler.List)
 			inboxes.POST("", inboxHandler.Create)
 			inboxes.GET("/:id", inboxHandler.Get)
-			inboxes.PUT("/:id", inboxHandler.Update)
-			inboxes.DELETE("/:id", inboxHandler.Delete)
+			inboxes.PUT("/:id", middleware.RequireRole("administrator"), inboxHandler.Update)
+			inboxes.DELETE("/:id", middleware.RequireRole("administrator"), inboxHandler.Delete)
+
+			// Inbox members (agents allowed to see the inbox)
+			inboxes.GET("/:id/members", inboxHandler.ListMembers)
+			inboxes.POST("/:id/members", middleware.RequireRole("administrator"), inboxHandler.AddMembers)
+			inboxes.DELETE("/:id/members/:user_id", middleware.RequireRole("administrator"), inboxHandler.RemoveMember)
+
+			// Working hours
+			inboxes.GET("/:id/working_hours", inboxHandler.GetWorkingHours)
+			inboxes.PUT("/:id/working_hours", middleware.RequireRole("administrator"), inboxHandler.UpdateWorkingHours)
+			inboxes.GET("/:id/open", inboxHandler.IsOpen)
+		}
+
+		// SLA policies
+		slaPolicies := api.Group("/sla_policies")
+		{
+			slaPolicies.GET("", slaHandler.List)
+			slaPolicies.GET("/report", slaHandler.Report)
+			slaPolicies.POST("", middleware.RequireRole("administrator"), slaHandler.Create)
+			slaPolicies.PUT("/:id", middleware.RequireRole("administrator"), slaHandler.Update)
+			slaPolicies.DELETE("/:id", middleware.RequireRole("administrator"), slaHandler.Delete)
 		}
 
-		// Webhooks (at account level)
-		webhooks := api.Group("/webhooks")
+		// Webhooks (at account level, administrators only)
+		webhooks := api.Group("/webhooks", middleware.RequireRole("administrator"))
 		{
 			webhooks.GET("", webhookHandler.List)
 			webhooks.POST("", webhookHandler.Create)
@@ -0,0 +1,178 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	_ "time/tzdata" // alpine images ship without a timezone database
+
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// DateLayout is the format of holiday dates
+const DateLayout = "2006-01-02"
+
+// maxSearchDays bounds lookups on schedules that rarely (or never) open
+const maxSearchDays = 366
+
+// Interval is an opening period within a day, in minutes since midnight
+type Interval struct {
+	Open  int
+	Close int
+}
+
+// Schedule is the weekly opening schedule of an inbox, evaluated in its timezone
+type Schedule struct {
+	Location *time.Location
+	Weekly   [7][]Interval // indexed by time.Weekday
+	Holidays map[string]bool
+}
+
+// ParseClock parses "HH:MM" into minutes since midnight; "24:00" marks the end of the day
+func ParseClock(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hour, &minute); err != nil {
+		return 0, fmt.Errorf("invalid time %q (HH:MM expected)", value)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// New builds a schedule from stored working hours and holidays
+func New(timezone string, hours []models.WorkingHour, holidays []models.InboxHoliday) (*Schedule, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+
+	s := &Schedule{Location: location, Holidays: make(map[string]bool)}
+	for _, hour := range hours {
+		if hour.DayOfWeek < 0 || hour.DayOfWeek > 6 {
+			return nil, fmt.Errorf("invalid day_of_week %d", hour.DayOfWeek)
+		}
+		open, err := ParseClock(hour.OpenTime)
+		if err != nil {
+			return nil, err
+		}
+		closing, err := ParseClock(hour.CloseTime)
+		if err != nil {
+			return nil, err
+		}
+		if closing <= open {
+			return nil, fmt.Errorf("close_time must be after open_time (%s-%s)", hour.OpenTime, hour.CloseTime)
+		}
+		s.Weekly[hour.DayOfWeek] = append(s.Weekly[hour.DayOfWeek], Interval{Open: open, Close: closing})
+	}
+	for day := range s.Weekly {
+		sort.Slice(s.Weekly[day], func(i, j int) bool {
+			return s.Weekly[day][i].Open < s.Weekly[day][j].Open
+		})
+	}
+	for _, holiday := range holidays {
+		s.Holidays[holiday.Date] = true
+	}
+	return s, nil
+}
+
+// Load returns the schedule of an inbox, or nil when working hours are disabled
+func Load(db *gorm.DB, inboxID uuid.UUID) (*Schedule, error) {
+	var inbox models.Inbox
+	if err := db.First(&inbox, "id = ?", inboxID).Error; err != nil {
+		return nil, err
+	}
+	if !inbox.WorkingHoursEnabled {
+		return nil, nil
+	}
+
+	var hours []models.WorkingHour
+	if err := db.Where("inbox_id = ?", inboxID).Find(&hours).Error; err != nil {
+		return nil, err
+	}
+	var holidays []models.InboxHoliday
+	if err := db.Where("inbox_id = ?", inboxID).Find(&holidays).Error; err != nil {
+		return nil, err
+	}
+
+	return New(inbox.Timezone, hours, holidays)
+}
+
+// intervalsOn returns the opening periods of a calendar day as absolute times
+func (s *Schedule) intervalsOn(day time.Time) [][2]time.Time {
+	if s.Holidays[day.Format(DateLayout)] {
+		return nil
+	}
+
+	var periods [][2]time.Time
+	for _, interval := range s.Weekly[day.Weekday()] {
+		periods = append(periods, [2]time.Time{
+			time.Date(day.Year(), day.Month(), day.Day(), 0, interval.Open, 0, 0, s.Location),
+			time.Date(day.Year(), day.Month(), day.Day(), 0, interval.Close, 0, 0, s.Location),
+		})
+	}
+	return periods
+}
+
+// startOfDay returns local midnight of the day containing t
+func (s *Schedule) startOfDay(t time.Time) time.Time {
+	local := t.In(s.Location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.Location)
+}
+
+// IsOpen reports whether the inbox is open at t
+func (s *Schedule) IsOpen(t time.Time) bool {
+	for _, period := range s.intervalsOn(s.startOfDay(t)) {
+		if !t.Before(period[0]) && t.Before(period[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the next instant at or after t when the inbox is open
+func (s *Schedule) NextOpen(t time.Time) (time.Time, bool) {
+	day := s.startOfDay(t)
+	for i := 0; i < maxSearchDays; i++ {
+		for _, period := range s.intervalsOn(day) {
+			if t.Before(period[1]) {
+				if t.Before(period[0]) {
+					return period[0], true
+				}
+				return t, true
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, false
+}
+
+// AddBusinessTime returns the instant at which d of opening time has elapsed after start.
+// A schedule without any opening hours falls back to wall-clock time.
+func (s *Schedule) AddBusinessTime(start time.Time, d time.Duration) time.Time {
+	remaining := d
+	day := s.startOfDay(start)
+	for i := 0; i < maxSearchDays; i++ {
+		for _, period := range s.intervalsOn(day) {
+			from := period[0]
+			if start.After(from) {
+				from = start
+			}
+			if !from.Before(period[1]) {
+				continue
+			}
+			available := period[1].Sub(from)
+			if remaining <= available {
+				return from.Add(remaining)
+			}
+			remaining -= available
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return start.Add(d)
+}
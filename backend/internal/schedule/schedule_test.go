@@ -0,0 +1,144 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nakamura/chatwoot-go/internal/models"
+)
+
+// weekdays builds a schedule open Monday to Friday in the given intervals, in São Paulo
+func weekdays(t *testing.T, holidays []string, intervals ...[2]string) *Schedule {
+	t.Helper()
+	var hours []models.WorkingHour
+	for day := 1; day <= 5; day++ {
+		for _, interval := range intervals {
+			hours = append(hours, models.WorkingHour{DayOfWeek: day, OpenTime: interval[0], CloseTime: interval[1]})
+		}
+	}
+	var days []models.InboxHoliday
+	for _, date := range holidays {
+		days = append(days, models.InboxHoliday{Date: date})
+	}
+	s, err := New("America/Sao_Paulo", hours, days)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// at returns a time in São Paulo; 2026-10-19 is a Monday
+func at(s *Schedule, day, hour, minute int) time.Time {
+	return time.Date(2026, time.October, day, hour, minute, 0, 0, s.Location)
+}
+
+func TestAddBusinessTime(t *testing.T) {
+	office := weekdays(t, nil, [2]string{"09:00", "17:00"})
+	lunch := weekdays(t, nil, [2]string{"09:00", "12:00"}, [2]string{"13:00", "17:00"})
+	holiday := weekdays(t, []string{"2026-10-20"}, [2]string{"09:00", "17:00"})
+
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		start    time.Time
+		d        time.Duration
+		want     time.Time
+	}{
+		{"within opening hours", office, at(office, 19, 10, 0), 2 * time.Hour, at(office, 19, 12, 0)},
+		{"ending exactly at closing", office, at(office, 19, 15, 0), 2 * time.Hour, at(office, 19, 17, 0)},
+		{"across closing time", office, at(office, 19, 16, 0), 2 * time.Hour, at(office, 20, 10, 0)},
+		{"before opening", office, at(office, 19, 7, 0), time.Hour, at(office, 19, 10, 0)},
+		{"after closing", office, at(office, 19, 20, 0), 30 * time.Minute, at(office, 20, 9, 30)},
+		{"over the weekend", office, at(office, 23, 16, 30), time.Hour, at(office, 26, 9, 30)},
+		{"starting on a weekend", office, at(office, 25, 12, 0), time.Hour, at(office, 26, 10, 0)},
+		{"across a lunch break", lunch, at(lunch, 19, 11, 0), 2 * time.Hour, at(lunch, 19, 14, 0)},
+		{"skipping a holiday", holiday, at(holiday, 19, 16, 0), 2 * time.Hour, at(holiday, 21, 10, 0)},
+		{"several days", office, at(office, 19, 9, 0), 20 * time.Hour, at(office, 21, 13, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.AddBusinessTime(tt.start, tt.d); !got.Equal(tt.want) {
+				t.Errorf("AddBusinessTime(%s, %s) = %s, want %s", tt.start, tt.d, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddBusinessTimeWithoutOpeningHours(t *testing.T) {
+	closed, err := New("UTC", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	if got, want := closed.AddBusinessTime(start, time.Hour), start.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("AddBusinessTime on a closed schedule = %s, want %s", got, want)
+	}
+}
+
+func TestIsOpenAndNextOpen(t *testing.T) {
+	s := weekdays(t, []string{"2026-10-20"}, [2]string{"09:00", "17:00"})
+
+	tests := []struct {
+		name     string
+		t        time.Time
+		open     bool
+		nextOpen time.Time
+	}{
+		{"at opening", at(s, 19, 9, 0), true, at(s, 19, 9, 0)},
+		{"before opening", at(s, 19, 8, 59), false, at(s, 19, 9, 0)},
+		{"at closing", at(s, 19, 17, 0), false, at(s, 21, 9, 0)},
+		{"on a holiday", at(s, 20, 12, 0), false, at(s, 21, 9, 0)},
+		{"on a Saturday", at(s, 24, 12, 0), false, at(s, 26, 9, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsOpen(tt.t); got != tt.open {
+				t.Errorf("IsOpen(%s) = %v, want %v", tt.t, got, tt.open)
+			}
+			next, ok := s.NextOpen(tt.t)
+			if !ok || !next.Equal(tt.nextOpen) {
+				t.Errorf("NextOpen(%s) = %s, %v, want %s", tt.t, next, ok, tt.nextOpen)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		value   string
+		minutes int
+		valid   bool
+	}{
+		{"09:00", 540, true},
+		{"00:00", 0, true},
+		{"23:59", 1439, true},
+		{"24:00", 1440, true},
+		{"24:01", 0, false},
+		{"12:60", 0, false},
+		{"-1:00", 0, false},
+		{"noon", 0, false},
+	}
+	for _, tt := range tests {
+		minutes, err := ParseClock(tt.value)
+		if (err == nil) != tt.valid || (tt.valid && minutes != tt.minutes) {
+			t.Errorf("ParseClock(%q) = %d, %v; want %d, valid %v", tt.value, minutes, err, tt.minutes, tt.valid)
+		}
+	}
+}
+
+func TestNewRejectsInvalidHours(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		hour     models.WorkingHour
+	}{
+		{"closing before opening", "UTC", models.WorkingHour{DayOfWeek: 1, OpenTime: "17:00", CloseTime: "09:00"}},
+		{"unknown day", "UTC", models.WorkingHour{DayOfWeek: 7, OpenTime: "09:00", CloseTime: "17:00"}},
+		{"unknown timezone", "Mars/Olympus", models.WorkingHour{DayOfWeek: 1, OpenTime: "09:00", CloseTime: "17:00"}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.timezone, []models.WorkingHour{tt.hour}, nil); err == nil {
+			t.Errorf("New with %s succeeded", tt.name)
+		}
+	}
+}
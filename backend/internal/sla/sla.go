@@ -0,0 +1,251 @@
+package sla
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/websocket"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MetricFirstResponse = "first_response"
+	MetricResolution    = "resolution"
+
+	EventWarning  = "warning"
+	EventBreached = "breached"
+)
+
+// Calendar measures durations in business time
+type Calendar interface {
+	// AddBusinessTime returns the instant at which d of business time has elapsed after start
+	AddBusinessTime(start time.Time, d time.Duration) time.Time
+}
+
+// AlwaysOpen is a Calendar without closed periods (24/7)
+type AlwaysOpen struct{}
+
+// AddBusinessTime implements Calendar
+func (AlwaysOpen) AddBusinessTime(start time.Time, d time.Duration) time.Time {
+	return start.Add(d)
+}
+
+// Service applies SLA policies to conversations and watches their deadlines
+type Service struct {
+	db    *gorm.DB
+	wsHub *websocket.Hub
+
+	// CalendarFor returns the business-hours calendar of an inbox.
+	// Defaults to AlwaysOpen until working hours are configured.
+	CalendarFor func(inboxID uuid.UUID) Calendar
+
+	// OnBreach, if set, is called once when a conversation misses a target
+	OnBreach func(conversation *models.Conversation, metric string)
+}
+
+// NewService creates a new SLA service
+func NewService(db *gorm.DB, wsHub *websocket.Hub) *Service {
+	return &Service{
+		db:    db,
+		wsHub: wsHub,
+		CalendarFor: func(uuid.UUID) Calendar {
+			return AlwaysOpen{}
+		},
+	}
+}
+
+// PolicyFor finds the active policy for a conversation of the given priority in an inbox,
+// preferring an inbox-specific one, then one for that priority
+func (s *Service) PolicyFor(accountID, inboxID uuid.UUID, priority string) (*models.SLAPolicy, error) {
+	var policy models.SLAPolicy
+	err := s.db.
+		Where("account_id = ? AND active = ? AND (inbox_id = ? OR inbox_id IS NULL)", accountID, true, inboxID).
+		Where("priority IN ?", []string{priority, ""}).
+		Order("inbox_id IS NULL, priority = '', created_at desc").
+		First(&policy).Error
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Apply attaches the matching SLA policy to a new conversation and computes its deadlines
+func (s *Service) Apply(conversation *models.Conversation) {
+	start := conversation.CreatedAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	s.apply(conversation, start)
+}
+
+// Reapply matches the policy again after the priority of a conversation changed and
+// recomputes the deadlines of its current cycle
+func (s *Service) Reapply(conversation *models.Conversation) {
+	start := conversation.CreatedAt
+	if conversation.SLAStartedAt != nil {
+		start = *conversation.SLAStartedAt
+	}
+	s.apply(conversation, start)
+}
+
+// Restart starts a new SLA cycle when a resolved conversation is reopened. The next
+// agent reply is the first response of the new cycle.
+func (s *Service) Restart(conversation *models.Conversation) {
+	conversation.FirstReplyCreatedAt = nil
+	s.update(conversation, map[string]interface{}{"first_reply_created_at": nil})
+	s.apply(conversation, time.Now())
+}
+
+// apply attaches the matching policy to a conversation with deadlines counted from start,
+// or detaches the previous one when no policy matches anymore
+func (s *Service) apply(conversation *models.Conversation, start time.Time) {
+	policy, err := s.PolicyFor(conversation.AccountID, conversation.InboxID, conversation.Priority)
+	if err != nil && conversation.SLAPolicyID == nil {
+		// No policy configured for this inbox
+		return
+	}
+
+	updates := map[string]interface{}{
+		"sla_policy_id":         nil,
+		"sla_started_at":        start,
+		"first_response_due_at": nil,
+		"resolution_due_at":     nil,
+	}
+	conversation.SLAPolicyID, conversation.SLAStartedAt = nil, &start
+	conversation.FirstResponseDueAt, conversation.ResolutionDueAt = nil, nil
+	if err != nil {
+		s.update(conversation, updates)
+		return
+	}
+
+	var calendar Calendar = AlwaysOpen{}
+	if policy.BusinessHoursOnly {
+		calendar = s.CalendarFor(conversation.InboxID)
+	}
+
+	updates["sla_policy_id"] = policy.ID
+	conversation.SLAPolicyID = &policy.ID
+	conversation.FirstResponseDueAt, conversation.ResolutionDueAt = Deadlines(policy, calendar, start)
+	if conversation.FirstResponseDueAt != nil {
+		updates["first_response_due_at"] = *conversation.FirstResponseDueAt
+	}
+	if conversation.ResolutionDueAt != nil {
+		updates["resolution_due_at"] = *conversation.ResolutionDueAt
+	}
+	s.update(conversation, updates)
+}
+
+// Deadlines returns the first response and resolution deadlines of a policy for a cycle
+// starting at start; a target of 0 minutes has no deadline
+func Deadlines(policy *models.SLAPolicy, calendar Calendar, start time.Time) (firstResponse, resolution *time.Time) {
+	if policy.FirstResponseMinutes > 0 {
+		due := calendar.AddBusinessTime(start, time.Duration(policy.FirstResponseMinutes)*time.Minute)
+		firstResponse = &due
+	}
+	if policy.ResolutionMinutes > 0 {
+		due := calendar.AddBusinessTime(start, time.Duration(policy.ResolutionMinutes)*time.Minute)
+		resolution = &due
+	}
+	return firstResponse, resolution
+}
+
+func (s *Service) update(conversation *models.Conversation, updates map[string]interface{}) {
+	if err := s.db.Model(&models.Conversation{}).Where("id = ?", conversation.ID).Updates(updates).Error; err != nil {
+		log.Printf("SLA: failed to apply policy to conversation %s: %v", conversation.ID, err)
+	}
+}
+
+// Run checks SLA deadlines periodically; it blocks and is meant to run in its own goroutine
+func (s *Service) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.Check(now)
+	}
+}
+
+// pausedStatuses are the conversation statuses whose SLA clocks do not run
+var pausedStatuses = []string{"resolved", "snoozed"}
+
+// Check emits sla.warning and sla.breached events for deadlines that are close or past.
+// Resolved and snoozed conversations are not waiting on anyone and never breach.
+func (s *Service) Check(now time.Time) {
+	s.checkMetric(now, MetricFirstResponse, "first_response_due_at", "first_reply_created_at IS NULL")
+	s.checkMetric(now, MetricResolution, "resolution_due_at", "")
+}
+
+func (s *Service) checkMetric(now time.Time, metric, dueColumn, pendingCondition string) {
+	// Conversations still waiting on this metric whose warning window has started
+	query := s.db.
+		Select("conversations.*").
+		Joins("JOIN sla_policies ON sla_policies.id = conversations.sla_policy_id").
+		Where("conversations."+dueColumn+" IS NOT NULL").
+		Where("conversations.status NOT IN ?", pausedStatuses)
+	if pendingCondition != "" {
+		query = query.Where("conversations." + pendingCondition)
+	}
+
+	var conversations []models.Conversation
+	err := query.
+		Where("conversations."+dueColumn+" - make_interval(mins => sla_policies.warning_minutes) <= ?", now).
+		Where("NOT EXISTS (SELECT 1 FROM sla_events WHERE sla_events.conversation_id = conversations.id AND sla_events.metric = ? AND sla_events.event_type = ? AND sla_events.due_at = conversations."+dueColumn+")", metric, EventBreached).
+		Find(&conversations).Error
+	if err != nil {
+		log.Printf("SLA: failed to check %s deadlines: %v", metric, err)
+		return
+	}
+
+	for _, conversation := range conversations {
+		due := *conversation.FirstResponseDueAt
+		if metric == MetricResolution {
+			due = *conversation.ResolutionDueAt
+		}
+
+		eventType := EventWarning
+		if !now.Before(due) {
+			eventType = EventBreached
+		}
+		s.record(&conversation, metric, eventType, due)
+	}
+}
+
+// record stores an SLA event once per conversation, metric, type and deadline, then broadcasts it
+func (s *Service) record(conversation *models.Conversation, metric, eventType string, due time.Time) {
+	event := models.SLAEvent{
+		AccountID:      conversation.AccountID,
+		ConversationID: conversation.ID,
+		SLAPolicyID:    *conversation.SLAPolicyID,
+		Metric:         metric,
+		EventType:      eventType,
+		DueAt:          due,
+	}
+
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	if result.Error != nil {
+		log.Printf("SLA: failed to record %s %s for conversation %s: %v", metric, eventType, conversation.ID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		// Already emitted
+		return
+	}
+
+	if s.wsHub != nil {
+		payload := map[string]interface{}{
+			"conversation_id": conversation.ID,
+			"inbox_id":        conversation.InboxID,
+			"sla_policy_id":   event.SLAPolicyID,
+			"metric":          metric,
+			"due_at":          due,
+		}
+		s.wsHub.BroadcastToRoom(conversation.AccountID, websocket.ConversationRoom(conversation.ID), "sla."+eventType, payload)
+		s.wsHub.BroadcastToRoom(conversation.AccountID, websocket.InboxRoom(conversation.InboxID), "sla."+eventType, payload)
+	}
+	if eventType == EventBreached && s.OnBreach != nil {
+		s.OnBreach(conversation, metric)
+	}
+}
@@ -0,0 +1,77 @@
+package sla
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nakamura/chatwoot-go/internal/models"
+	"github.com/nakamura/chatwoot-go/internal/schedule"
+)
+
+func TestDeadlines(t *testing.T) {
+	office, err := schedule.New("UTC", []models.WorkingHour{
+		{DayOfWeek: 1, OpenTime: "09:00", CloseTime: "17:00"},
+		{DayOfWeek: 2, OpenTime: "09:00", CloseTime: "17:00"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Monday 16:00 UTC
+	start := time.Date(2026, time.October, 19, 16, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		policy            models.SLAPolicy
+		calendar          Calendar
+		wantFirstResponse *time.Time
+		wantResolution    *time.Time
+	}{
+		{
+			name:              "both targets, around the clock",
+			policy:            models.SLAPolicy{FirstResponseMinutes: 30, ResolutionMinutes: 240},
+			calendar:          AlwaysOpen{},
+			wantFirstResponse: ptr(start.Add(30 * time.Minute)),
+			wantResolution:    ptr(start.Add(4 * time.Hour)),
+		},
+		{
+			name:              "business hours carry over to the next day",
+			policy:            models.SLAPolicy{FirstResponseMinutes: 30, ResolutionMinutes: 120},
+			calendar:          office,
+			wantFirstResponse: ptr(start.Add(30 * time.Minute)),
+			wantResolution:    ptr(time.Date(2026, time.October, 20, 10, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:           "no first response target",
+			policy:         models.SLAPolicy{ResolutionMinutes: 60},
+			calendar:       AlwaysOpen{},
+			wantResolution: ptr(start.Add(time.Hour)),
+		},
+		{
+			name:     "no targets",
+			policy:   models.SLAPolicy{},
+			calendar: AlwaysOpen{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firstResponse, resolution := Deadlines(&tt.policy, tt.calendar, start)
+			if !sameTime(firstResponse, tt.wantFirstResponse) {
+				t.Errorf("first response due %v, want %v", firstResponse, tt.wantFirstResponse)
+			}
+			if !sameTime(resolution, tt.wantResolution) {
+				t.Errorf("resolution due %v, want %v", resolution, tt.wantResolution)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
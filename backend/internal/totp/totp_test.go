@@ -0,0 +1,115 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// The SHA-1 secret of the RFC 6238 test vectors ("12345678901234567890")
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(step int64) string {
+		c, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfcSecret, code(step), step, true},
+		{"previous step", rfcSecret, code(step - 1), step - 1, true},
+		{"next step", rfcSecret, code(step + 1), step + 1, true},
+		{"two steps ago", rfcSecret, code(step - 2), 0, false},
+		{"two steps ahead", rfcSecret, code(step + 2), 0, false},
+		{"spaces", rfcSecret, " 050 471 ", step, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", step, true},
+		{"wrong code", rfcSecret, "123456", 0, false},
+		{"too short", rfcSecret, "05047", 0, false},
+		{"too long", rfcSecret, "0504710", 0, false},
+		{"empty", rfcSecret, "", 0, false},
+		{"invalid secret", "not base32!", "050471", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := Validate(tt.secret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret %q has %d characters, want 32", secret, len(secret))
+	}
+	if _, err := Code(secret, 0); err != nil {
+		t.Errorf("generated secret does not decode: %v", err)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Error("two generated secrets are equal")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("Chatwoot", "agent@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Chatwoot:agent@example.com" {
+		t.Errorf("URI = %s", uri)
+	}
+	query := uri.Query()
+	for key, want := range map[string]string{
+		"secret":    rfcSecret,
+		"issuer":    "Chatwoot",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Protocol is a wire format spoken over a connection instead of the native one,
+// negotiated by WebSocket subprotocol (or the event stream format)
+type Protocol interface {
+	// Welcome is written when the connection opens, nil for none
+	Welcome() []byte
+	// Heartbeat is written every HeartbeatInterval (0 for none), on top of WebSocket pings
+	Heartbeat() []byte
+	HeartbeatInterval() time.Duration
+	// Handle processes a frame received from the client
+	Handle(c *Client, data []byte)
+	// Encode returns the frames that carry a message to the client, none when it has
+	// no subscription the message belongs to
+	Encode(c *Client, message *Message) [][]byte
+}
+
+// ActionCableSubprotocol is the subprotocol of the Rails ActionCable JSON protocol
+// spoken by Chatwoot clients and SDKs
+const ActionCableSubprotocol = "actioncable-v1-json"
+
+// actionCableChannel is the only channel served, like Chatwoot's RoomChannel
+const actionCableChannel = "RoomChannel"
+
+// ActionCable speaks the ActionCable protocol. Subscriptions name a RoomChannel and,
+// optionally, a room:
+//
+//	{"channel": "RoomChannel"}                          the notifications of the account
+//	{"channel": "RoomChannel", "conversation_id": "…"}  a conversation
+//	{"channel": "RoomChannel", "inbox_id": "…"}         an inbox
+//	{"channel": "RoomChannel", "room": "…"}             any room name
+//
+// Chatwoot's pubsub_token and user_id are ignored (the connection is authenticated
+// when it opens); account_id must be the account of the connection. Messages are
+// delivered as {"identifier": "…", "message": {"event": "…", "data": …}}.
+type ActionCable struct {
+	mu            sync.Mutex
+	subscriptions map[string]string // identifier -> requested room
+}
+
+// NewActionCable creates the protocol state of a connection
+func NewActionCable() *ActionCable {
+	return &ActionCable{subscriptions: make(map[string]string)}
+}
+
+func (p *ActionCable) Welcome() []byte {
+	return []byte(`{"type":"welcome"}`)
+}
+
+// Heartbeat is the ping ActionCable clients expect every 3 seconds; they reconnect
+// when it stops
+func (p *ActionCable) Heartbeat() []byte {
+	return []byte(`{"type":"ping","message":` + strconv.FormatInt(time.Now().Unix(), 10) + `}`)
+}
+
+func (p *ActionCable) HeartbeatInterval() time.Duration {
+	return 3 * time.Second
+}
+
+func (p *ActionCable) Handle(c *Client, data []byte) {
+	var command struct {
+		Command    string `json:"command"`
+		Identifier string `json:"identifier"`
+		Data       string `json:"data"`
+	}
+	if err := json.Unmarshal(data, &command); err != nil {
+		log.Printf("Error unmarshaling ActionCable command: %v", err)
+		return
+	}
+
+	switch command.Command {
+	case "subscribe":
+		p.mu.Lock()
+		_, subscribed := p.subscriptions[command.Identifier]
+		p.mu.Unlock()
+		if subscribed {
+			return
+		}
+
+		room, ok := p.room(c, command.Identifier)
+		if !ok || !c.Subscribe(command.Identifier, room) {
+			c.queue(p.frame(command.Identifier, "reject_subscription"))
+			return
+		}
+		p.mu.Lock()
+		p.subscriptions[command.Identifier] = room
+		p.mu.Unlock()
+		c.queue(p.frame(command.Identifier, "confirm_subscription"))
+
+	case "unsubscribe":
+		p.mu.Lock()
+		delete(p.subscriptions, command.Identifier)
+		p.mu.Unlock()
+		c.Unsubscribe(command.Identifier)
+
+	case "message":
+		p.perform(c, command.Data)
+
+	default:
+		log.Printf("Unknown ActionCable command: %s", command.Command)
+	}
+}
+
+// perform runs a channel action: typing_on/typing_off ({"conversation_id", "is_private"},
+// as in Chatwoot's toggle_typing_status), resume ({"seq"}) and update_presence, which is
+// accepted but not needed since presence follows the connection
+func (p *ActionCable) perform(c *Client, data string) {
+	var action struct {
+		Action         string   `json:"action"`
+		ConversationID string   `json:"conversation_id"`
+		IsPrivate      bool     `json:"is_private"`
+		Seq            *float64 `json:"seq"`
+	}
+	if err := json.Unmarshal([]byte(data), &action); err != nil {
+		log.Printf("Error unmarshaling ActionCable action: %v", err)
+		return
+	}
+
+	switch action.Action {
+	case "typing_on", "typing_off":
+		messageType := "typing.start"
+		if action.Action == "typing_off" {
+			messageType = "typing.stop"
+		}
+		c.handleTyping(&Message{Type: messageType, Payload: map[string]interface{}{
+			"conversation_id": action.ConversationID,
+			"private":         action.IsPrivate,
+		}})
+
+	case "resume":
+		if action.Seq != nil {
+			c.resume(*action.Seq)
+		} else {
+			c.resume(nil)
+		}
+
+	case "update_presence":
+
+	default:
+		log.Printf("Unknown ActionCable action: %s", action.Action)
+	}
+}
+
+// room reads the room a subscription identifier asks for
+func (p *ActionCable) room(c *Client, identifier string) (string, bool) {
+	var params struct {
+		Channel        string      `json:"channel"`
+		AccountID      interface{} `json:"account_id"`
+		ConversationID string      `json:"conversation_id"`
+		InboxID        string      `json:"inbox_id"`
+		Room           string      `json:"room"`
+	}
+	if err := json.Unmarshal([]byte(identifier), &params); err != nil || params.Channel != actionCableChannel {
+		return "", false
+	}
+	// Chatwoot clients send the account ID they were given, as a string or a number
+	if params.AccountID != nil && fmt.Sprint(params.AccountID) != c.AccountID.String() {
+		return "", false
+	}
+
+	switch {
+	case params.Room != "":
+		return params.Room, true
+	case params.ConversationID != "":
+		id, err := uuid.Parse(params.ConversationID)
+		return ConversationRoom(id), err == nil
+	case params.InboxID != "":
+		id, err := uuid.Parse(params.InboxID)
+		return InboxRoom(id), err == nil
+	default:
+		return NotificationsRoom, true
+	}
+}
+
+// Encode delivers a room message on every subscription that joined the room, and a
+// message to the user (no room) on their notification subscriptions
+func (p *ActionCable) Encode(c *Client, message *Message) [][]byte {
+	p.mu.Lock()
+	subscriptions := make(map[string]string, len(p.subscriptions))
+	for identifier, room := range p.subscriptions {
+		subscriptions[identifier] = room
+	}
+	p.mu.Unlock()
+
+	body := map[string]interface{}{"event": message.Type, "data": message.Payload}
+	if message.Seq != 0 {
+		body["seq"] = message.Seq
+	}
+
+	var frames [][]byte
+	for identifier, room := range subscriptions {
+		if message.Room == "" && room != NotificationsRoom {
+			continue
+		}
+		if message.Room != "" && !c.subscribedVia(identifier, message.Room) {
+			continue
+		}
+		frame, err := json.Marshal(map[string]interface{}{"identifier": identifier, "message": body})
+		if err != nil {
+			log.Printf("Error marshaling message: %v", err)
+			return nil
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// frame is a control frame about a subscription
+func (p *ActionCable) frame(identifier, frameType string) []byte {
+	frame, _ := json.Marshal(map[string]string{"identifier": identifier, "type": frameType})
+	return frame
+}
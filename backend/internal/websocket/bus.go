@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// busChannel is the Redis channel broadcasts are fanned out on between nodes
+const busChannel = "chatwoot:websocket:broadcast"
+
+// busOutboxSize bounds the broadcasts waiting to be published; when Redis is slow or
+// down further broadcasts only reach the clients of this node
+const busOutboxSize = 1024
+
+// envelope is a broadcast as published to the other nodes
+type envelope struct {
+	Node    string          `json:"node"`
+	Type    string          `json:"type"`
+	Room    string          `json:"room,omitempty"`
+	UserID  *uuid.UUID      `json:"user_id,omitempty"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+	// Disconnect asks the nodes to close the matching connections instead of
+	// delivering a message
+	Disconnect *disconnectFilter `json:"disconnect,omitempty"`
+}
+
+// redisBus publishes the broadcasts of this node and delivers those of the others
+type redisBus struct {
+	client *redis.Client
+	nodeID string
+	outbox chan []byte
+	down   atomic.Bool
+}
+
+// UseRedis fans broadcasts out through Redis pub/sub so that clients connected to
+// other nodes receive them too, and keeps the event log in Redis streams shared by
+// all nodes. Without a client the hub stays local to this node.
+// An empty nodeID is derived from the hostname.
+func (h *Hub) UseRedis(client *redis.Client, nodeID string) {
+	if client == nil {
+		log.Println("⚠️ WebSocket broadcasts are local to this node (Redis unavailable)")
+		return
+	}
+	if nodeID == "" {
+		nodeID = defaultNodeID()
+	}
+
+	bus := &redisBus{client: client, nodeID: nodeID, outbox: make(chan []byte, busOutboxSize)}
+	h.NodeID = nodeID
+	h.bus = bus
+	h.events = NewRedisEventLog(client, eventLogSize)
+
+	go bus.publishLoop()
+	go bus.subscribeLoop(h)
+	log.Printf("✅ WebSocket broadcasts fanned out through Redis (node %s)", nodeID)
+}
+
+// defaultNodeID is the hostname (the container ID under Docker) with a random suffix,
+// so that restarted nodes never receive their predecessor's echoes as their own
+func defaultNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "node"
+	}
+	return host + "-" + uuid.NewString()[:8]
+}
+
+// publish queues a broadcast for the other nodes without blocking the caller
+func (b *redisBus) publish(message *Message, userID *uuid.UUID) {
+	raw, err := json.Marshal(message.Payload)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+	b.enqueue(&envelope{Node: b.nodeID, Type: message.Type, Room: message.Room, UserID: userID, Seq: message.Seq, Payload: raw})
+}
+
+// publishDisconnect asks the other nodes to close the connections matching a filter
+func (b *redisBus) publishDisconnect(filter disconnectFilter, reason string) {
+	b.enqueue(&envelope{Node: b.nodeID, Type: reason, Disconnect: &filter})
+}
+
+// enqueue queues an envelope for publishing without blocking the caller
+func (b *redisBus) enqueue(env *envelope) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	select {
+	case b.outbox <- data:
+	default:
+		log.Printf("WebSocket bus outbox full, %s not sent to other nodes", env.Type)
+	}
+}
+
+func (b *redisBus) publishLoop() {
+	for data := range b.outbox {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := b.client.Publish(ctx, busChannel, data).Err()
+		cancel()
+
+		// Log transitions only, not every failed publish while Redis is down
+		if err != nil {
+			if !b.down.Swap(true) {
+				log.Printf("⚠️ WebSocket bus publish failed, broadcasts stay local until Redis is back: %v", err)
+			}
+			continue
+		}
+		if b.down.Swap(false) {
+			log.Println("✅ WebSocket bus publishing again")
+		}
+	}
+}
+
+// subscribeLoop delivers the broadcasts of the other nodes to the local clients. The
+// subscription reconnects by itself; broadcasts published meanwhile are missed.
+func (b *redisBus) subscribeLoop(h *Hub) {
+	pubsub := b.client.Subscribe(context.Background(), busChannel)
+	defer pubsub.Close()
+
+	for msg := range pubsub.Channel() {
+		var env envelope
+		if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+			log.Printf("Error unmarshaling bus message: %v", err)
+			continue
+		}
+		if env.Node == b.nodeID {
+			continue
+		}
+
+		if env.Disconnect != nil {
+			h.closeClients(*env.Disconnect, env.Type)
+			continue
+		}
+		if env.UserID != nil {
+			h.sendToUser(*env.UserID, &Message{Type: env.Type, Payload: env.Payload})
+			continue
+		}
+		h.Broadcast <- &Message{Type: env.Type, Payload: env.Payload, Room: env.Room, Seq: env.Seq}
+	}
+}
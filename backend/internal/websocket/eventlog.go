@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Event log limits
+const (
+	// eventLogSize is how many events per account are kept for replay
+	eventLogSize = 1000
+	// eventLogTTL drops the log of an account after a day without events
+	eventLogTTL = 24 * time.Hour
+)
+
+// LoggedEvent is a broadcast kept for replay, numbered within its account
+type LoggedEvent struct {
+	Seq  uint64
+	Data []byte // the Message, without its sequence number
+}
+
+// EventLog numbers the events of each account and keeps the latest ones so that
+// reconnecting clients can catch up
+type EventLog interface {
+	// Append stores an event and returns its sequence number
+	Append(ctx context.Context, accountID uuid.UUID, data []byte) (uint64, error)
+	// Since returns the events after seq and the latest sequence number. complete is
+	// false when some of those events are no longer kept (or seq is unknown), in
+	// which case the client has to reload instead.
+	Since(ctx context.Context, accountID uuid.UUID, seq uint64) (events []LoggedEvent, latest uint64, complete bool, err error)
+	// Latest returns the sequence number of the last event of the account
+	Latest(ctx context.Context, accountID uuid.UUID) (uint64, error)
+}
+
+// MemoryEventLog keeps the events of this node in memory. Sequence numbers restart
+// with the process.
+type MemoryEventLog struct {
+	mu       sync.Mutex
+	size     int
+	accounts map[uuid.UUID]*eventRing
+}
+
+type eventRing struct {
+	seq    uint64
+	events []LoggedEvent
+}
+
+func NewMemoryEventLog(size int) *MemoryEventLog {
+	return &MemoryEventLog{size: size, accounts: make(map[uuid.UUID]*eventRing)}
+}
+
+func (l *MemoryEventLog) Append(ctx context.Context, accountID uuid.UUID, data []byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ring, ok := l.accounts[accountID]
+	if !ok {
+		ring = &eventRing{}
+		l.accounts[accountID] = ring
+	}
+	ring.seq++
+	ring.events = append(ring.events, LoggedEvent{Seq: ring.seq, Data: data})
+	if len(ring.events) > l.size {
+		ring.events = append([]LoggedEvent(nil), ring.events[len(ring.events)-l.size:]...)
+	}
+	return ring.seq, nil
+}
+
+func (l *MemoryEventLog) Since(ctx context.Context, accountID uuid.UUID, seq uint64) ([]LoggedEvent, uint64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ring, ok := l.accounts[accountID]
+	if !ok {
+		return nil, 0, seq == 0, nil
+	}
+	if seq > ring.seq {
+		return nil, ring.seq, false, nil
+	}
+	if seq == ring.seq {
+		return nil, ring.seq, true, nil
+	}
+	if len(ring.events) == 0 || ring.events[0].Seq > seq+1 {
+		return nil, ring.seq, false, nil
+	}
+
+	start := int(seq + 1 - ring.events[0].Seq)
+	return append([]LoggedEvent(nil), ring.events[start:]...), ring.seq, true, nil
+}
+
+func (l *MemoryEventLog) Latest(ctx context.Context, accountID uuid.UUID) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ring, ok := l.accounts[accountID]; ok {
+		return ring.seq, nil
+	}
+	return 0, nil
+}
+
+// RedisEventLog keeps the events in a Redis stream per account, shared by all nodes.
+// Stream entry IDs are the sequence numbers.
+type RedisEventLog struct {
+	client *redis.Client
+	size   int
+}
+
+func NewRedisEventLog(client *redis.Client, size int) *RedisEventLog {
+	return &RedisEventLog{client: client, size: size}
+}
+
+// appendScript numbers and stores an event atomically, so that entries are added in
+// sequence order even when several nodes append at once
+var appendScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], seq .. '-0', 'event', ARGV[1])
+redis.call('PEXPIRE', KEYS[2], ARGV[3])
+return seq
+`)
+
+// eventLogKeys share a hash tag so that both live on the same cluster slot. The
+// counter never expires, so sequence numbers are not reused.
+func eventLogKeys(accountID uuid.UUID) (seqKey, streamKey string) {
+	tag := "chatwoot:events:{" + accountID.String() + "}"
+	return tag + ":seq", tag + ":stream"
+}
+
+func (l *RedisEventLog) Append(ctx context.Context, accountID uuid.UUID, data []byte) (uint64, error) {
+	seqKey, streamKey := eventLogKeys(accountID)
+	seq, err := appendScript.Run(ctx, l.client, []string{seqKey, streamKey}, data, l.size, eventLogTTL.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(seq), nil
+}
+
+func (l *RedisEventLog) Latest(ctx context.Context, accountID uuid.UUID) (uint64, error) {
+	seqKey, _ := eventLogKeys(accountID)
+	latest, err := l.client.Get(ctx, seqKey).Uint64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return latest, err
+}
+
+func (l *RedisEventLog) Since(ctx context.Context, accountID uuid.UUID, seq uint64) ([]LoggedEvent, uint64, bool, error) {
+	_, streamKey := eventLogKeys(accountID)
+
+	latest, err := l.Latest(ctx, accountID)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if seq > latest {
+		return nil, latest, false, nil
+	}
+	if seq == latest {
+		return nil, latest, true, nil
+	}
+
+	// Trimming is approximate, so more than size entries may be kept
+	entries, err := l.client.XRangeN(ctx, streamKey, fmt.Sprintf("%d-0", seq+1), "+", int64(2*l.size)).Result()
+	if err != nil {
+		return nil, latest, false, err
+	}
+
+	events := make([]LoggedEvent, 0, len(entries))
+	for _, entry := range entries {
+		id, _, _ := strings.Cut(entry.ID, "-")
+		entrySeq, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		data, _ := entry.Values["event"].(string)
+		events = append(events, LoggedEvent{Seq: entrySeq, Data: []byte(data)})
+	}
+
+	// The first missed event must still be there, and nothing may be cut off at the end
+	if len(events) == 0 || events[0].Seq != seq+1 || events[len(events)-1].Seq < latest {
+		return nil, latest, false, nil
+	}
+	return events, latest, true, nil
+}
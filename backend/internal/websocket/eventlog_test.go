@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryEventLogSince(t *testing.T) {
+	ctx := context.Background()
+	account, empty := uuid.New(), uuid.New()
+
+	// Five events in a log keeping three: 3, 4 and 5 are left
+	eventLog := NewMemoryEventLog(3)
+	for i := 1; i <= 5; i++ {
+		seq, err := eventLog.Append(ctx, account, []byte(fmt.Sprint(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != uint64(i) {
+			t.Fatalf("Append %d returned seq %d", i, seq)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		account      uuid.UUID
+		seq          uint64
+		wantSeqs     []uint64
+		wantLatest   uint64
+		wantComplete bool
+	}{
+		{"up to date", account, 5, nil, 5, true},
+		{"missed one", account, 4, []uint64{5}, 5, true},
+		{"missed all kept", account, 2, []uint64{3, 4, 5}, 5, true},
+		{"missed a dropped event", account, 1, nil, 5, false},
+		{"from the start", account, 0, nil, 5, false},
+		{"ahead of the log", account, 9, nil, 5, false},
+		{"account without events", empty, 0, nil, 0, true},
+		{"unknown sequence of an account without events", empty, 3, nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, latest, complete, err := eventLog.Since(ctx, tt.account, tt.seq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var seqs []uint64
+			for _, event := range events {
+				seqs = append(seqs, event.Seq)
+				if string(event.Data) != fmt.Sprint(event.Seq) {
+					t.Errorf("event %d has data %q", event.Seq, event.Data)
+				}
+			}
+			if !reflect.DeepEqual(seqs, tt.wantSeqs) || latest != tt.wantLatest || complete != tt.wantComplete {
+				t.Errorf("Since(%d) = %v, %d, %v, want %v, %d, %v",
+					tt.seq, seqs, latest, complete, tt.wantSeqs, tt.wantLatest, tt.wantComplete)
+			}
+		})
+	}
+
+	if latest, _ := eventLog.Latest(ctx, account); latest != 5 {
+		t.Errorf("Latest = %d, want 5", latest)
+	}
+	if latest, _ := eventLog.Latest(ctx, empty); latest != 0 {
+		t.Errorf("Latest of an account without events = %d, want 0", latest)
+	}
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	account := uuid.New()
+
+	// Events 1-5 alternate between a room the client is in and one it is not;
+	// the log keeps 3 to 5
+	hub := NewHub()
+	hub.events = NewMemoryEventLog(3)
+	for i := 1; i <= 5; i++ {
+		room := "joined"
+		if i%2 == 0 {
+			room = "other"
+		}
+		data, _ := json.Marshal(&Message{Type: "message.created", Payload: i, Room: room})
+		if _, err := hub.events.Append(ctx, account, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type frame struct {
+		Type    string
+		Seq     uint64
+		Payload interface{}
+	}
+	resumed := func(messageType string, replayed int) frame {
+		payload := map[string]interface{}{"seq": float64(5)}
+		if messageType == "resume.completed" {
+			payload["replayed"] = float64(replayed)
+		}
+		return frame{messageType, 5, payload}
+	}
+	seq := func(n uint64) *uint64 { return &n }
+
+	tests := []struct {
+		name string
+		last *uint64
+		want []frame
+	}{
+		{"first connection", nil, []frame{resumed("resume.completed", 0)}},
+		{"up to date", seq(5), []frame{resumed("resume.completed", 0)}},
+		{"missed events of joined rooms only", seq(2), []frame{
+			{"message.created", 3, float64(3)},
+			{"message.created", 5, float64(5)},
+			resumed("resume.completed", 2),
+		}},
+		{"missed dropped events", seq(1), []frame{resumed("resync_required", 0)}},
+		{"unknown sequence", seq(8), []frame{resumed("resync_required", 0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{
+				ID:        uuid.New(),
+				AccountID: account,
+				Hub:       hub,
+				Send:      make(chan []byte, 16),
+				Done:      make(chan struct{}),
+				Rooms:     map[string]bool{"joined": true},
+			}
+
+			client.Resume(tt.last)
+
+			var got []frame
+			for len(client.Send) > 0 {
+				var message frame
+				if err := json.Unmarshal(<-client.Send, &message); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resume sent %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
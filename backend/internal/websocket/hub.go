@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -11,22 +12,78 @@ import (
 
 // Client represents a WebSocket client
 type Client struct {
-	ID     uuid.UUID
-	UserID uuid.UUID
-	Conn   *websocket.Conn
-	Send   chan []byte
-	Hub    *Hub
-	Rooms  map[string]bool // conversation_id -> subscribed
-	mu     sync.RWMutex
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	AccountID uuid.UUID
+	// SessionID is the session of the token the connection was opened with
+	SessionID uuid.UUID
+	Role      string
+	Conn      *websocket.Conn
+	Send      chan []byte
+	Hub       *Hub
+	Rooms     map[string]bool // room -> subscribed
+	// Protocol is the wire format negotiated for the connection; nil is the native
+	// {type, payload, room} format
+	Protocol Protocol
+	// ResolveRoom returns the rooms a subscription joins (an alias such as
+	// "notifications" can stand for several); an empty result denies it
+	ResolveRoom func(room string) []string
+	// OnTyping is called when the agent starts or stops typing in a conversation room
+	// the client subscribed to
+	OnTyping func(conversationID uuid.UUID, typing, private bool)
+	// OnClose is called once the connection is closed
+	OnClose func()
+	// Done is closed by Close for clients without a WebSocket connection (event
+	// streams), whose handler then ends the response
+	Done        chan struct{}
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+	aliases     map[string][]string
+	typing      map[uuid.UUID]*time.Timer // conversation -> automatic typing.stop
+	expiry      *time.Timer
+	slow        sync.Once
+	mu          sync.RWMutex
 }
 
+// Close codes sent to clients
+const (
+	// CloseTokenExpired asks the client to refresh its token and reconnect
+	CloseTokenExpired = 4001
+	// CloseSlowConsumer tells a client that fell behind that it missed events and
+	// should reconnect and reload its data
+	CloseSlowConsumer = 4002
+	// CloseRevoked tells a client that its session was revoked or its access changed;
+	// it should refresh its token (signing out when that fails) and reconnect
+	CloseRevoked = 4003
+)
+
+// Connection limits
+const (
+	// writeWait is the time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+	// pongWait is the time allowed to read the next pong (or any message) from the peer
+	pongWait = 60 * time.Second
+	// pingPeriod sends pings often enough for pongs to arrive before pongWait
+	pingPeriod = (pongWait * 9) / 10
+	// maxMessageSize is the largest message accepted from the peer
+	maxMessageSize = 4096
+	// typingTimeout stops a typing indicator the client did not renew or stop
+	typingTimeout = 10 * time.Second
+)
+
 // Hub maintains active clients and broadcasts messages
 type Hub struct {
 	Clients    map[uuid.UUID]*Client
 	Broadcast  chan *Message
 	Register   chan *Client
 	Unregister chan *Client
-	mu         sync.RWMutex
+	// NodeID identifies this node on the Redis bus (see UseRedis)
+	NodeID   string
+	bus      *redisBus
+	events   EventLog
+	recorder chan roomEvent
+	mu       sync.RWMutex
 }
 
 // Message represents a WebSocket message
@@ -34,6 +91,7 @@ type Message struct {
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload"`
 	Room    string      `json:"room,omitempty"` // conversation_id or account_id
+	Seq     uint64      `json:"seq,omitempty"`  // position in the account's event log, for resume
 }
 
 // NewHub creates a new Hub
@@ -43,11 +101,15 @@ func NewHub() *Hub {
 		Broadcast:  make(chan *Message, 256),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
+		events:     NewMemoryEventLog(eventLogSize),
+		recorder:   make(chan roomEvent, recordQueueSize),
 	}
 }
 
 // Run starts the hub
 func (h *Hub) Run() {
+	go h.recordLoop()
+
 	for {
 		select {
 		case client := <-h.Register:
@@ -94,22 +156,62 @@ func (h *Hub) broadcastMessage(message *Message) {
 			}
 		}
 
-		select {
-		case client.Send <- data:
-		default:
-			// Client's send channel is full, skip
-			log.Printf("Client %s send channel full, skipping message", client.ID)
+		client.deliver(message, data)
+	}
+}
+
+// deliver queues a message for the client in its wire format; data is the message in
+// the native format, when already encoded
+func (c *Client) deliver(message *Message, data []byte) {
+	for _, frame := range c.frames(message, data) {
+		c.queue(frame)
+	}
+}
+
+// frames encodes a message in the wire format of the client
+func (c *Client) frames(message *Message, data []byte) [][]byte {
+	if c.Protocol != nil {
+		return c.Protocol.Encode(c, message)
+	}
+
+	if data == nil {
+		var err error
+		if data, err = json.Marshal(message); err != nil {
+			log.Printf("Error marshaling message: %v", err)
+			return nil
 		}
 	}
+	return [][]byte{data}
 }
 
 // ReadPump reads messages from the WebSocket connection
 func (c *Client) ReadPump() {
 	defer func() {
+		c.mu.Lock()
+		if c.expiry != nil {
+			c.expiry.Stop()
+		}
+		typing := c.typing
+		c.typing = nil
+		c.mu.Unlock()
+		for conversationID, timer := range typing {
+			if timer.Stop() && c.OnTyping != nil {
+				c.OnTyping(conversationID, false, false)
+			}
+		}
 		c.Hub.Unregister <- c
 		c.Conn.Close()
+		if c.OnClose != nil {
+			c.OnClose()
+		}
 	}()
 
+	c.Conn.SetReadLimit(maxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.Conn.SetPongHandler(func(string) error {
+		return c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
 	for {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
@@ -119,6 +221,14 @@ func (c *Client) ReadPump() {
 			break
 		}
 
+		// Any message proves the peer is alive, not only pongs
+		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		if c.Protocol != nil {
+			c.Protocol.Handle(c, message)
+			continue
+		}
+
 		// Handle incoming messages (subscribe/unsubscribe from rooms)
 		var msg Message
 		if err := json.Unmarshal(message, &msg); err != nil {
@@ -130,14 +240,56 @@ func (c *Client) ReadPump() {
 	}
 }
 
-// WritePump writes messages to the WebSocket connection
+// WritePump writes messages to the WebSocket connection and pings the peer so that
+// dead connections are noticed
 func (c *Client) WritePump() {
-	defer c.Conn.Close()
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+	}()
 
-	for message := range c.Send {
-		if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			log.Printf("Error writing message: %v", err)
-			return
+	// Protocols may have their own greeting and heartbeat on top of WebSocket pings
+	var heartbeat <-chan time.Time
+	if c.Protocol != nil {
+		if welcome := c.Protocol.Welcome(); welcome != nil {
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.TextMessage, welcome); err != nil {
+				return
+			}
+		}
+		if interval := c.Protocol.HeartbeatInterval(); interval > 0 {
+			heartbeatTicker := time.NewTicker(interval)
+			defer heartbeatTicker.Stop()
+			heartbeat = heartbeatTicker.C
+		}
+	}
+
+	for {
+		select {
+		case message, ok := <-c.Send:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// The hub unregistered the client
+				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("Error writing message: %v", err)
+				return
+			}
+
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-heartbeat:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.TextMessage, c.Protocol.Heartbeat()); err != nil {
+				return
+			}
 		}
 	}
 }
@@ -147,41 +299,257 @@ func (c *Client) handleMessage(msg *Message) {
 	switch msg.Type {
 	case "subscribe":
 		if room, ok := msg.Payload.(string); ok {
-			c.mu.Lock()
-			c.Rooms[room] = true
-			c.mu.Unlock()
-			log.Printf("Client %s subscribed to room: %s", c.ID, room)
+			if !c.Subscribe(room, room) {
+				c.sendDirect("subscription.denied", room)
+			}
 		}
 
 	case "unsubscribe":
 		if room, ok := msg.Payload.(string); ok {
-			c.mu.Lock()
-			delete(c.Rooms, room)
-			c.mu.Unlock()
-			log.Printf("Client %s unsubscribed from room: %s", c.ID, room)
+			c.Unsubscribe(room)
 		}
 
+	case "resume":
+		c.resume(msg.Payload)
+
+	case "typing.start", "typing.stop":
+		c.handleTyping(msg)
+
 	default:
 		log.Printf("Unknown message type: %s", msg.Type)
 	}
 }
 
-// BroadcastToRoom sends a message to all clients in a specific room
-func (h *Hub) BroadcastToRoom(room string, messageType string, payload interface{}) {
-	h.Broadcast <- &Message{
+// Subscribe joins the rooms a room name resolves to, remembered under key (the room
+// name itself, or a protocol's subscription identifier). It reports whether the
+// subscription was allowed.
+func (c *Client) Subscribe(key, room string) bool {
+	var rooms []string
+	if c.ResolveRoom != nil {
+		rooms = c.ResolveRoom(room)
+	}
+	if len(rooms) == 0 {
+		log.Printf("Client %s denied subscription to room: %s", c.ID, room)
+		return false
+	}
+
+	c.mu.Lock()
+	if c.aliases == nil {
+		c.aliases = make(map[string][]string)
+	}
+	c.aliases[key] = rooms
+	for _, r := range rooms {
+		c.Rooms[r] = true
+	}
+	c.mu.Unlock()
+	log.Printf("Client %s subscribed to room: %s", c.ID, room)
+	return true
+}
+
+// Unsubscribe leaves the rooms joined under key, except those another subscription
+// still needs
+func (c *Client) Unsubscribe(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rooms, ok := c.aliases[key]
+	if !ok {
+		rooms = []string{key}
+	}
+	delete(c.aliases, key)
+	for _, r := range rooms {
+		delete(c.Rooms, r)
+	}
+	for _, others := range c.aliases {
+		for _, r := range others {
+			c.Rooms[r] = true
+		}
+	}
+	log.Printf("Client %s unsubscribed from room: %s", c.ID, key)
+}
+
+// subscribedVia reports whether the subscription under key joined room
+func (c *Client) subscribedVia(key, room string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, r := range c.aliases[key] {
+		if r == room {
+			return true
+		}
+	}
+	return false
+}
+
+// handleTyping relays typing indicators. The payload is {"conversation_id": "...",
+// "private": false}, private meaning a note that the contact must not see being typed.
+// Only state changes are reported: a typing.start renews the indicator, which stops
+// by itself after typingTimeout.
+func (c *Client) handleTyping(msg *Message) {
+	payload, _ := msg.Payload.(map[string]interface{})
+	idStr, _ := payload["conversation_id"].(string)
+	private, _ := payload["private"].(bool)
+	conversationID, err := uuid.Parse(idStr)
+	if err != nil || c.OnTyping == nil {
+		return
+	}
+
+	c.mu.Lock()
+	if !c.Rooms[ConversationRoom(conversationID)] {
+		c.mu.Unlock()
+		c.sendDirect("subscription.denied", idStr)
+		return
+	}
+
+	timer, active := c.typing[conversationID]
+	if msg.Type == "typing.stop" {
+		if active && timer.Stop() {
+			delete(c.typing, conversationID)
+			c.mu.Unlock()
+			c.OnTyping(conversationID, false, private)
+			return
+		}
+		c.mu.Unlock()
+		return
+	}
+
+	if active && timer.Stop() {
+		timer.Reset(typingTimeout)
+		c.mu.Unlock()
+		return
+	}
+	if c.typing == nil {
+		c.typing = make(map[uuid.UUID]*time.Timer)
+	}
+	var expire *time.Timer
+	expire = time.AfterFunc(typingTimeout, func() {
+		c.mu.Lock()
+		current := c.typing[conversationID] == expire
+		if current {
+			delete(c.typing, conversationID)
+		}
+		c.mu.Unlock()
+		if current {
+			c.OnTyping(conversationID, false, private)
+		}
+	})
+	c.typing[conversationID] = expire
+	c.mu.Unlock()
+	c.OnTyping(conversationID, true, private)
+}
+
+// CloseAt closes the connection with a close code at the given time, e.g. when the
+// token it was opened with expires
+func (c *Client) CloseAt(at time.Time, code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.expiry != nil {
+		c.expiry.Stop()
+	}
+	c.expiry = time.AfterFunc(time.Until(at), func() {
+		log.Printf("Closing client %s: %s", c.ID, reason)
+		c.Close(code, reason)
+	})
+}
+
+// Close sends a close frame and closes the connection; the read pump then unregisters the client
+func (c *Client) Close(code int, reason string) {
+	if c.Conn == nil {
+		c.closeOnce.Do(func() {
+			c.mu.Lock()
+			if c.expiry != nil {
+				c.expiry.Stop()
+			}
+			c.closeCode, c.closeReason = code, reason
+			c.mu.Unlock()
+			close(c.Done)
+		})
+		return
+	}
+
+	message := websocket.FormatCloseMessage(code, reason)
+	c.Conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	c.Conn.Close()
+}
+
+// CloseStatus returns the code and reason a connectionless client was closed with
+func (c *Client) CloseStatus() (int, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closeCode, c.closeReason
+}
+
+// queue hands a message to the write pump. A full send buffer means the client has
+// fallen hundreds of messages behind, so rather than silently dropping events it is
+// disconnected with CloseSlowConsumer and resyncs when it reconnects.
+func (c *Client) queue(data []byte) {
+	select {
+	case c.Send <- data:
+	default:
+		c.slow.Do(func() {
+			log.Printf("Client %s send channel full, disconnecting slow consumer", c.ID)
+			// Closing writes to the connection; never block the broadcaster on it
+			go c.Close(CloseSlowConsumer, "slow consumer")
+		})
+	}
+}
+
+// sendDirect queues a message for this client only
+func (c *Client) sendDirect(messageType string, payload interface{}) {
+	c.deliver(&Message{Type: messageType, Payload: payload}, nil)
+}
+
+// BroadcastToRoom sends a message to all clients in a specific room, on every node.
+// The message is numbered in the event log of the account so that clients can
+// resume after a disconnect; numbering happens in the background (see recordLoop).
+func (h *Hub) BroadcastToRoom(accountID uuid.UUID, room string, messageType string, payload interface{}) {
+	message := &Message{
 		Type:    messageType,
 		Payload: payload,
 		Room:    room,
 	}
+
+	select {
+	case h.recorder <- roomEvent{accountID: accountID, message: message}:
+	default:
+		log.Printf("WebSocket event log queue full, %s sent without sequence number", messageType)
+		h.send(message)
+	}
 }
 
-// BroadcastToUser sends a message to a specific user
+// send broadcasts a message to the clients of this node and publishes it to the others
+func (h *Hub) send(message *Message) {
+	h.Broadcast <- message
+	if h.bus != nil {
+		h.bus.publish(message, nil)
+	}
+}
+
+// BroadcastTransient sends a message to all clients in a room, on every node, without
+// logging it for replay (e.g. typing indicators, which are stale after a reconnect)
+func (h *Hub) BroadcastTransient(room string, messageType string, payload interface{}) {
+	message := &Message{
+		Type:    messageType,
+		Payload: payload,
+		Room:    room,
+	}
+	h.send(message)
+}
+
+// BroadcastToUser sends a message to a specific user, on every node
 func (h *Hub) BroadcastToUser(userID uuid.UUID, messageType string, payload interface{}) {
 	message := &Message{
 		Type:    messageType,
 		Payload: payload,
 	}
+	h.sendToUser(userID, message)
+	if h.bus != nil {
+		h.bus.publish(message, &userID)
+	}
+}
 
+// sendToUser sends a message to the clients of a user connected to this node
+func (h *Hub) sendToUser(userID uuid.UUID, message *Message) {
 	data, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Error marshaling message: %v", err)
@@ -193,11 +561,82 @@ func (h *Hub) BroadcastToUser(userID uuid.UUID, messageType string, payload inte
 
 	for _, client := range h.Clients {
 		if client.UserID == userID {
-			select {
-			case client.Send <- data:
-			default:
-				log.Printf("Client %s send channel full, skipping message", client.ID)
-			}
+			client.deliver(message, data)
 		}
 	}
 }
+
+// disconnectFilter selects connections to close; a zero ID matches any
+type disconnectFilter struct {
+	UserID    uuid.UUID `json:"user_id"`
+	AccountID uuid.UUID `json:"account_id"`
+	SessionID uuid.UUID `json:"session_id"`
+}
+
+func (f disconnectFilter) matches(c *Client) bool {
+	return (f.UserID == uuid.Nil || c.UserID == f.UserID) &&
+		(f.AccountID == uuid.Nil || c.AccountID == f.AccountID) &&
+		(f.SessionID == uuid.Nil || c.SessionID == f.SessionID)
+}
+
+// DisconnectUser closes the connections of a user on every node, e.g. once every
+// token of the user was invalidated. Like the other Disconnect methods it does
+// nothing on a nil hub.
+func (h *Hub) DisconnectUser(userID uuid.UUID) {
+	if userID != uuid.Nil {
+		h.disconnect(disconnectFilter{UserID: userID}, "session revoked")
+	}
+}
+
+// DisconnectMember closes the connections of a user to one account on every node, e.g.
+// after a role change, so that the client subscribes again with its new access
+func (h *Hub) DisconnectMember(userID, accountID uuid.UUID) {
+	if userID != uuid.Nil && accountID != uuid.Nil {
+		h.disconnect(disconnectFilter{UserID: userID, AccountID: accountID}, "access changed")
+	}
+}
+
+// DisconnectAccount closes every connection to an account on every node
+func (h *Hub) DisconnectAccount(accountID uuid.UUID) {
+	if accountID != uuid.Nil {
+		h.disconnect(disconnectFilter{AccountID: accountID}, "access changed")
+	}
+}
+
+// DisconnectSession closes the connections opened with a session on every node, once
+// the session is revoked
+func (h *Hub) DisconnectSession(sessionID uuid.UUID) {
+	if sessionID != uuid.Nil {
+		h.disconnect(disconnectFilter{SessionID: sessionID}, "session revoked")
+	}
+}
+
+// disconnect closes the matching connections of this node and asks the other nodes to
+// close theirs
+func (h *Hub) disconnect(filter disconnectFilter, reason string) {
+	if h == nil {
+		return
+	}
+	h.closeClients(filter, reason)
+	if h.bus != nil {
+		h.bus.publishDisconnect(filter, reason)
+	}
+}
+
+// closeClients closes the matching connections of this node
+func (h *Hub) closeClients(filter disconnectFilter, reason string) {
+	h.mu.RLock()
+	var clients []*Client
+	for _, client := range h.Clients {
+		if filter.matches(client) {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		log.Printf("Closing client %s: %s", client.ID, reason)
+		// Closing writes to the connection; never block the caller on it
+		go client.Close(CloseRevoked, reason)
+	}
+}
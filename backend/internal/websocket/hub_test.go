@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDisconnect(t *testing.T) {
+	user, otherUser := uuid.New(), uuid.New()
+	account, otherAccount := uuid.New(), uuid.New()
+	session, otherSession := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		disconnect func(h *Hub)
+		closed     []string
+	}{
+		{"user", func(h *Hub) { h.DisconnectUser(user) }, []string{"session", "other session", "other account"}},
+		{"member", func(h *Hub) { h.DisconnectMember(user, account) }, []string{"session", "other session"}},
+		{"account", func(h *Hub) { h.DisconnectAccount(account) }, []string{"session", "other session", "other user"}},
+		{"session", func(h *Hub) { h.DisconnectSession(session) }, []string{"session"}},
+		{"nil session", func(h *Hub) { h.DisconnectSession(uuid.Nil) }, nil},
+		{"nil user", func(h *Hub) { h.DisconnectUser(uuid.Nil) }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			clients := map[string]*Client{
+				"session":       {UserID: user, AccountID: account, SessionID: session},
+				"other session": {UserID: user, AccountID: account, SessionID: otherSession},
+				"other account": {UserID: user, AccountID: otherAccount, SessionID: uuid.New()},
+				"other user":    {UserID: otherUser, AccountID: account, SessionID: uuid.New()},
+			}
+			for _, client := range clients {
+				client.ID = uuid.New()
+				client.Done = make(chan struct{})
+				hub.Clients[client.ID] = client
+			}
+
+			tt.disconnect(hub)
+
+			want := make(map[string]bool)
+			for _, name := range tt.closed {
+				want[name] = true
+			}
+			for name, client := range clients {
+				if got := waitClosed(client, want[name]); got != want[name] {
+					t.Errorf("client %q closed = %v, want %v", name, got, want[name])
+					continue
+				}
+				if code, _ := client.CloseStatus(); want[name] && code != CloseRevoked {
+					t.Errorf("client %q closed with %d, want %d", name, code, CloseRevoked)
+				}
+			}
+		})
+	}
+}
+
+func TestDisconnectNilHub(t *testing.T) {
+	var hub *Hub
+	hub.DisconnectUser(uuid.New())
+	hub.DisconnectSession(uuid.New())
+}
+
+// waitClosed reports whether a connectionless client gets closed, waiting a moment
+// when it is expected to be
+func waitClosed(client *Client, expected bool) bool {
+	wait := 50 * time.Millisecond
+	if expected {
+		wait = time.Second
+	}
+	select {
+	case <-client.Done:
+		return true
+	case <-time.After(wait):
+		return false
+	}
+}
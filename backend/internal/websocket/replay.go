@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event log writer limits
+const (
+	// recordQueueSize bounds the room broadcasts waiting to be numbered; beyond it
+	// broadcasts go out without a sequence number
+	recordQueueSize = 1024
+	// eventLogBackoff is how long broadcasts go out unnumbered after the event log
+	// failed, so that a Redis outage does not hold each of them for the timeout
+	eventLogBackoff = 5 * time.Second
+)
+
+// roomEvent is a room broadcast waiting to be numbered in its account's event log
+type roomEvent struct {
+	accountID uuid.UUID
+	message   *Message
+}
+
+// recordLoop numbers room broadcasts and sends them one at a time, so that they leave
+// this node in sequence order while handlers never wait for the event log
+func (h *Hub) recordLoop() {
+	var retryAt time.Time
+	for event := range h.recorder {
+		if time.Now().After(retryAt) {
+			seq, err := h.record(event.accountID, event.message)
+			if err != nil {
+				log.Printf("Failed to log %s for account %s, broadcasting without sequence numbers for %s: %v",
+					event.message.Type, event.accountID, eventLogBackoff, err)
+				retryAt = time.Now().Add(eventLogBackoff)
+			}
+			event.message.Seq = seq
+		}
+		h.send(event.message)
+	}
+}
+
+// record appends a room message to the event log of its account and returns its
+// sequence number; messages without an account are not logged
+func (h *Hub) record(accountID uuid.UUID, message *Message) (uint64, error) {
+	if h.events == nil || accountID == uuid.Nil {
+		return 0, nil
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return h.events.Append(ctx, accountID, data)
+}
+
+// resume handles a resume message, whose payload is the last sequence number seen
+func (c *Client) resume(payload interface{}) {
+	last, ok := payload.(float64)
+	if !ok || last < 0 {
+		c.Resume(nil)
+		return
+	}
+	seq := uint64(last)
+	c.Resume(&seq)
+}
+
+// Resume answers a client that reconnects with the sequence number of the last event
+// it saw: the events of its account since then, in the rooms it subscribed to again,
+// followed by resume.completed with the latest sequence number. When those events are
+// no longer all kept it answers resync_required and the client reloads its data.
+// Without a sequence number (a first connection) only resume.completed is sent.
+func (c *Client) Resume(last *uint64) {
+	if c.Hub == nil || c.Hub.events == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if last == nil {
+		latest, err := c.Hub.events.Latest(ctx, c.AccountID)
+		if err != nil {
+			log.Printf("Failed to read event log of account %s: %v", c.AccountID, err)
+			c.sendResume("resync_required", latest, 0)
+			return
+		}
+		c.sendResume("resume.completed", latest, 0)
+		return
+	}
+
+	events, latest, complete, err := c.Hub.events.Since(ctx, c.AccountID, *last)
+	if err != nil {
+		log.Printf("Failed to read event log of account %s: %v", c.AccountID, err)
+	}
+	if err != nil || !complete {
+		c.sendResume("resync_required", latest, 0)
+		return
+	}
+
+	replayed := 0
+	for _, event := range events {
+		var message struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+			Room    string          `json:"room"`
+		}
+		if err := json.Unmarshal(event.Data, &message); err != nil {
+			continue
+		}
+
+		c.mu.RLock()
+		inRoom := message.Room == "" || c.Rooms[message.Room]
+		c.mu.RUnlock()
+		if !inRoom {
+			continue
+		}
+
+		// A replay can be larger than the send buffer, so wait for the write pump
+		// instead of treating the client as slow
+		for _, frame := range c.frames(&Message{Type: message.Type, Payload: message.Payload, Room: message.Room, Seq: event.Seq}, nil) {
+			select {
+			case c.Send <- frame:
+			case <-time.After(writeWait):
+				c.Close(CloseSlowConsumer, "slow consumer")
+				return
+			case <-c.Done:
+				return
+			}
+		}
+		replayed++
+	}
+
+	c.sendResume("resume.completed", latest, replayed)
+}
+
+// sendResume answers a resume. The message carries the latest sequence number so that
+// clients tracking event IDs (SSE) continue from it.
+func (c *Client) sendResume(messageType string, latest uint64, replayed int) {
+	payload := map[string]interface{}{"seq": latest}
+	if messageType == "resume.completed" {
+		payload["replayed"] = replayed
+	}
+	c.deliver(&Message{Type: messageType, Payload: payload, Seq: latest}, nil)
+}
@@ -0,0 +1,53 @@
+package websocket
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Room names. Conversation rooms are the bare conversation ID; account and inbox
+// rooms are prefixed so that they cannot be confused with conversations.
+const (
+	// NotificationsRoom is the alias clients subscribe to for the notifications of
+	// their account; the server resolves it to account and inbox rooms
+	NotificationsRoom = "notifications"
+
+	accountRoomPrefix = "account:"
+	inboxRoomPrefix   = "inbox:"
+)
+
+// ConversationRoom is the room of a conversation
+func ConversationRoom(conversationID uuid.UUID) string {
+	return conversationID.String()
+}
+
+// AccountRoom is the room of account-wide events
+func AccountRoom(accountID uuid.UUID) string {
+	return accountRoomPrefix + accountID.String()
+}
+
+// InboxRoom is the room of an inbox's notifications, for the agents who can see it
+func InboxRoom(inboxID uuid.UUID) string {
+	return inboxRoomPrefix + inboxID.String()
+}
+
+// RoomKind tells the kind of a room and the ID it refers to. Unknown names return
+// an empty kind.
+func RoomKind(room string) (kind string, id uuid.UUID) {
+	name := room
+	switch {
+	case strings.HasPrefix(room, accountRoomPrefix):
+		kind, name = "account", strings.TrimPrefix(room, accountRoomPrefix)
+	case strings.HasPrefix(room, inboxRoomPrefix):
+		kind, name = "inbox", strings.TrimPrefix(room, inboxRoomPrefix)
+	default:
+		kind = "conversation"
+	}
+
+	id, err := uuid.Parse(name)
+	if err != nil {
+		return "", uuid.Nil
+	}
+	return kind, id
+}
@@ -0,0 +1,49 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+)
+
+// SSE encodes messages as Server-Sent Events for clients that read the hub through
+// an event stream instead of a WebSocket. Each event is named after the message type,
+// carries the native message as data and, when numbered, the sequence number as its
+// ID so that EventSource resumes with Last-Event-ID. The stream is receive-only.
+type SSE struct{}
+
+// Welcome sets how long EventSource waits before reconnecting
+func (SSE) Welcome() []byte {
+	return []byte("retry: 3000\n\n")
+}
+
+// Heartbeat is a comment that keeps proxies from closing an idle stream
+func (SSE) Heartbeat() []byte {
+	return []byte(": ping\n\n")
+}
+
+func (SSE) HeartbeatInterval() time.Duration {
+	return 25 * time.Second
+}
+
+func (SSE) Handle(c *Client, data []byte) {}
+
+func (SSE) Encode(c *Client, message *Message) [][]byte {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return nil
+	}
+
+	var frame bytes.Buffer
+	if message.Seq != 0 {
+		frame.WriteString("id: " + strconv.FormatUint(message.Seq, 10) + "\n")
+	}
+	frame.WriteString("event: " + message.Type + "\n")
+	frame.WriteString("data: ")
+	frame.Write(data) // JSON has no raw newlines
+	frame.WriteString("\n\n")
+	return [][]byte{frame.Bytes()}
+}
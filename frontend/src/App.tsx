@@ -2,6 +2,7 @@ import { BrowserRouter, Routes, Route, Navigate } from 'react-router-dom'
 import { useAuthStore } from './stores/authStore'
 import LoginPage from './pages/LoginPage'
 import RegisterPage from './pages/RegisterPage'
+import SSOCallbackPage from './pages/SSOCallbackPage'
 import ConversationsPage from './pages/ConversationsPage'
 import ContactsPage from './pages/ContactsPage'
 import SettingsPage from './pages/SettingsPage'
@@ -16,6 +17,7 @@ function App() {
         {/* Public routes */}
         <Route path="/login" element={!isAuthenticated ? <LoginPage /> : <Navigate to="/conversations" />} />
         <Route path="/register" element={!isAuthenticated ? <RegisterPage /> : <Navigate to="/conversations" />} />
+        <Route path="/sso/callback" element={<SSOCallbackPage />} />
 
         {/* Protected routes */}
         <Route element={isAuthenticated ? <Layout /> : <Navigate to="/login" />}>
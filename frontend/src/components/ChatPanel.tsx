@@ -18,7 +18,10 @@ interface Message {
   content: string
   content_type: string
   message_type: 'incoming' | 'outgoing' | 'activity'
+  private?: boolean
   created_at: string
+  edited_at?: string | null
+  content_attributes?: { deleted?: boolean } | null
   sender?: { id: string; name: string; avatar?: string }
   attachments?: Attachment[]
 }
@@ -31,6 +34,7 @@ interface ChatPanelProps {
 export default function ChatPanel({ conversationId, contactName }: ChatPanelProps) {
   const [messages, setMessages] = useState<Message[]>([])
   const [newMessage, setNewMessage] = useState('')
+  const [isPrivate, setIsPrivate] = useState(false)
   const [isLoading, setIsLoading] = useState(false)
   const [isSending, setIsSending] = useState(false)
   const [pendingFiles, setPendingFiles] = useState<File[]>([])
@@ -85,6 +89,7 @@ export default function ChatPanel({ conversationId, contactName }: ChatPanelProp
         content: newMessage,
         content_type: attachmentUrls.length > 0 ? attachmentUrls[0].file_type : 'text',
         message_type: 'outgoing',
+        private: isPrivate,
         attachments: attachmentUrls
       }
 
@@ -194,15 +199,28 @@ export default function ChatPanel({ conversationId, contactName }: ChatPanelProp
             <Paperclip className="w-5 h-5" />
           </button>
 
+          {/* Private Note Toggle */}
+          <button
+            onClick={() => setIsPrivate(!isPrivate)}
+            className={`p-3 rounded-lg transition-colors ${
+              isPrivate ? 'text-yellow-400 bg-yellow-900/40' : 'text-gray-400 hover:text-white hover:bg-gray-700'
+            }`}
+            title={isPrivate ? 'Nota privada (visível apenas para agentes)' : 'Escrever nota privada'}
+          >
+            <FileText className="w-5 h-5" />
+          </button>
+
           {/* Text Input */}
           <div className="flex-1 relative">
             <textarea
               value={newMessage}
               onChange={(e) => setNewMessage(e.target.value)}
               onKeyDown={handleKeyDown}
-              placeholder="Digite sua mensagem..."
+              placeholder={isPrivate ? 'Nota privada... use @nome para mencionar um agente' : 'Digite sua mensagem...'}
               rows={1}
-              className="w-full bg-gray-700 border border-gray-600 rounded-lg px-4 py-3 text-white placeholder-gray-400 focus:outline-none focus:border-primary-500 resize-none max-h-32"
+              className={`w-full border rounded-lg px-4 py-3 text-white placeholder-gray-400 focus:outline-none resize-none max-h-32 ${
+                isPrivate ? 'bg-yellow-900/30 border-yellow-700 focus:border-yellow-500' : 'bg-gray-700 border-gray-600 focus:border-primary-500'
+              }`}
               style={{ minHeight: '48px' }}
             />
           </div>
@@ -229,6 +247,7 @@ export default function ChatPanel({ conversationId, contactName }: ChatPanelProp
 function MessageBubble({ message }: { message: Message }) {
   const isOutgoing = message.message_type === 'outgoing'
   const isActivity = message.message_type === 'activity'
+  const isDeleted = message.content_attributes?.deleted === true
 
   if (isActivity) {
     return (
@@ -244,7 +263,9 @@ function MessageBubble({ message }: { message: Message }) {
     <div className={`flex ${isOutgoing ? 'justify-end' : 'justify-start'}`}>
       <div 
         className={`max-w-[70%] rounded-2xl px-4 py-2 ${
-          isOutgoing 
+          message.private
+            ? 'bg-yellow-900/60 border border-yellow-700 text-yellow-50 rounded-br-md'
+            : isOutgoing 
             ? 'bg-primary-600 text-white rounded-br-md' 
             : 'bg-gray-700 text-white rounded-bl-md'
         }`}
@@ -259,12 +280,15 @@ function MessageBubble({ message }: { message: Message }) {
         )}
 
         {/* Text Content */}
-        {message.content && (
+        {isDeleted ? (
+          <p className="italic opacity-70">Mensagem apagada</p>
+        ) : message.content && (
           <p className="whitespace-pre-wrap break-words">{message.content}</p>
         )}
 
         {/* Timestamp */}
         <div className={`text-[10px] mt-1 ${isOutgoing ? 'text-primary-200' : 'text-gray-400'}`}>
+          {message.edited_at && !isDeleted && 'editada · '}
           {new Date(message.created_at).toLocaleTimeString('pt-BR', { hour: '2-digit', minute: '2-digit' })}
         </div>
       </div>
@@ -5,10 +5,11 @@ import { useWebSocket } from '../hooks/useWebSocket'
 import clsx from 'clsx'
 import { useState, useRef, useEffect } from 'react'
 import { NavLink } from 'react-router-dom'
+import { notificationsApi } from '../lib/api'
 
 export default function Header() {
   const { user } = useAuthStore()
-  const { unreadCount, notifications, markAllAsRead } = useNotificationStore()
+  const { unreadCount, notifications, markAsRead, markAllAsRead } = useNotificationStore()
   const [showNotifications, setShowNotifications] = useState(false)
   const dropdownRef = useRef<HTMLDivElement>(null)
 
@@ -72,7 +73,10 @@ export default function Header() {
                 <div className="flex items-center gap-2">
                   {unreadCount > 0 && (
                     <button
-                      onClick={markAllAsRead}
+                      onClick={() => {
+                        markAllAsRead()
+                        notificationsApi.markAllRead().catch(() => {})
+                      }}
                       className="text-xs text-primary-400 hover:text-primary-300"
                       type="button"
                     >
@@ -103,7 +107,15 @@ export default function Header() {
                         'block p-3 border-b border-gray-800 hover:bg-gray-800 cursor-pointer transition-colors',
                         !notification.read && 'bg-gray-800/50'
                       )}
-                      onClick={() => setShowNotifications(false)}
+                      onClick={() => {
+                        setShowNotifications(false)
+                        if (!notification.read) {
+                          markAsRead(notification.id)
+                          if (notification.serverId) {
+                            notificationsApi.markRead(notification.serverId).catch(() => {})
+                          }
+                        }
+                      }}
                     >
                       <div className="flex items-start gap-3">
                         <div className={clsx(
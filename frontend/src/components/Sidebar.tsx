@@ -1,6 +1,7 @@
 import { NavLink } from 'react-router-dom'
 import { MessageSquare, Users, Settings, LogOut } from 'lucide-react'
 import { useAuthStore } from '../stores/authStore'
+import { authApi } from '../lib/api'
 import { useNotificationStore } from '../stores/notificationStore'
 import clsx from 'clsx'
 
@@ -14,6 +15,10 @@ export default function Sidebar() {
   const { user, logout } = useAuthStore()
   const { unreadCount } = useNotificationStore()
 
+  const handleLogout = () => {
+    authApi.logout().catch(() => {}).finally(logout)
+  }
+
   return (
     <div className="w-64 bg-gray-800 border-r border-gray-700 flex flex-col">
       {/* Logo */}
@@ -69,7 +74,7 @@ export default function Sidebar() {
           </div>
         </div>
         <button
-          onClick={logout}
+          onClick={handleLogout}
           type="button"
           className="w-full flex items-center gap-2 px-4 py-2 text-sm text-red-400 hover:bg-gray-700 rounded-lg transition-colors"
         >
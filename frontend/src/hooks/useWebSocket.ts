@@ -1,6 +1,15 @@
 import { useEffect, useRef, useCallback } from 'react'
+import { useQueryClient } from '@tanstack/react-query'
 import { useAuthStore } from '../stores/authStore'
 import { useNotificationStore } from '../stores/notificationStore'
+import { refreshAccessToken } from '../lib/api'
+
+// Close code sent by the server when the token of the connection expires
+const CLOSE_TOKEN_EXPIRED = 4001
+// Close code sent by the server when this client fell behind and missed events
+const CLOSE_SLOW_CONSUMER = 4002
+// Close code sent by the server when the session was revoked or the user's access changed
+const CLOSE_REVOKED = 4003
 
 // Build WebSocket URL dynamically based on current origin
 const getWebSocketUrl = () => {
@@ -16,23 +25,45 @@ interface WebSocketMessage {
   type: string
   payload: any
   room?: string
+  seq?: number
+}
+
+// Sequence numbers are per account, read from the token
+const tokenAccountId = (token: string): string | null => {
+  try {
+    return JSON.parse(atob(token.split('.')[1].replace(/-/g, '+').replace(/_/g, '/'))).account_id ?? null
+  } catch {
+    return null
+  }
 }
 
 export function useWebSocket() {
   const wsRef = useRef<WebSocket | null>(null)
   const reconnectTimeoutRef = useRef<NodeJS.Timeout>()
+  // Last event seen, so that a reconnect can resume from it
+  const lastSeqRef = useRef<{ accountId: string | null; seq: number } | null>(null)
+  // Recently seen events: replayed and live events can overlap and arrive out of order
+  const seenSeqsRef = useRef<Set<number>>(new Set())
   const { token, isAuthenticated } = useAuthStore()
   const { addNotification } = useNotificationStore()
+  const queryClient = useQueryClient()
 
   const connect = useCallback(() => {
     if (!isAuthenticated || !token) return
 
     // Close existing connection
     if (wsRef.current) {
-      wsRef.current.close()
+      const previous = wsRef.current
+      wsRef.current = null
+      previous.close()
     }
 
     const ws = new WebSocket(`${getWebSocketUrl()}?token=${token}`)
+    const accountId = tokenAccountId(token)
+    if (lastSeqRef.current?.accountId !== accountId) {
+      lastSeqRef.current = null
+      seenSeqsRef.current.clear()
+    }
 
     ws.onopen = () => {
       console.log('🔌 WebSocket connected')
@@ -42,19 +73,62 @@ export function useWebSocket() {
         type: 'subscribe',
         payload: 'notifications'
       }))
+
+      // Ask for the events missed while disconnected (none on the first connection)
+      ws.send(JSON.stringify({
+        type: 'resume',
+        payload: lastSeqRef.current?.seq ?? null
+      }))
     }
 
     ws.onmessage = (event) => {
       try {
         const message: WebSocketMessage = JSON.parse(event.data)
+        if (message.type === 'resume.completed' || message.type === 'resync_required') {
+          if (message.type === 'resync_required') {
+            // Too much was missed to replay: reload everything
+            queryClient.invalidateQueries()
+            lastSeqRef.current = null
+          }
+          if (!lastSeqRef.current || lastSeqRef.current.seq < message.payload.seq) {
+            lastSeqRef.current = { accountId, seq: message.payload.seq }
+          }
+          return
+        }
+        if (message.seq) {
+          const seen = seenSeqsRef.current
+          if (seen.has(message.seq)) return
+          seen.add(message.seq)
+          if (seen.size > 1000) {
+            seen.delete(seen.values().next().value as number)
+          }
+          if (!lastSeqRef.current || lastSeqRef.current.seq < message.seq) {
+            lastSeqRef.current = { accountId, seq: message.seq }
+          }
+        }
         handleMessage(message)
       } catch (err) {
         console.error('Failed to parse WebSocket message:', err)
       }
     }
 
-    ws.onclose = () => {
+    ws.onclose = (event) => {
+      // Closed on purpose (sign out, or a new token replaced this connection)
+      if (wsRef.current !== ws) return
+
       console.log('🔌 WebSocket disconnected')
+      if (event.code === CLOSE_TOKEN_EXPIRED || event.code === CLOSE_REVOKED) {
+        // The new token reconnects through the effect below; a revoked session cannot
+        // refresh and signs out
+        refreshAccessToken().catch(() => useAuthStore.getState().logout())
+        return
+      }
+      if (event.code === CLOSE_SLOW_CONSUMER) {
+        // Events were lost: reload everything and reconnect right away
+        queryClient.invalidateQueries()
+        connect()
+        return
+      }
       // Reconnect after 3 seconds
       reconnectTimeoutRef.current = setTimeout(() => {
         if (isAuthenticated) {
@@ -68,7 +142,7 @@ export function useWebSocket() {
     }
 
     wsRef.current = ws
-  }, [isAuthenticated, token])
+  }, [isAuthenticated, token, queryClient])
 
   const handleMessage = useCallback((message: WebSocketMessage) => {
     switch (message.type) {
@@ -83,6 +157,30 @@ export function useWebSocket() {
         })
         break
 
+      case 'notification.created': {
+        const notification = message.payload
+        const actor = notification.actor?.name || 'Um agente'
+        const content: Record<string, { title: string; body: string }> = {
+          conversation_assignment: { title: 'Conversa atribuída', body: `${actor} atribuiu uma conversa a você` },
+          conversation_mention: { title: 'Você foi mencionado', body: `${actor} mencionou você em uma nota privada` },
+          conversation_creation: { title: 'Nova conversa', body: 'Uma nova conversa começou em uma das suas caixas de entrada' },
+          sla_breach: { title: 'SLA violado', body: 'Uma conversa perdeu o prazo do SLA' },
+        }
+        const { title, body } = content[notification.notification_type] || { title: 'Notificação', body: '' }
+        addNotification({
+          type: notification.notification_type === 'sla_breach' ? 'system' : 'conversation',
+          title,
+          body,
+          conversationId: notification.conversation_id,
+          serverId: notification.id,
+        })
+        break
+      }
+
+      case 'message.updated':
+      case 'message.deleted':
+        break
+
       case 'conversation.created':
         addNotification({
           type: 'conversation',
@@ -104,6 +202,19 @@ export function useWebSocket() {
         }
         break
 
+      case 'presence.update': {
+        // Keep our own availability in sync when the server changes it (e.g. went offline)
+        const { user, updateUser } = useAuthStore.getState()
+        if (message.payload.user_id === user?.id) {
+          updateUser({ availability: message.payload.availability })
+        }
+        break
+      }
+
+      case 'typing.start':
+      case 'typing.stop':
+        break
+
       default:
         console.log('Unknown message type:', message.type)
     }
@@ -114,8 +225,9 @@ export function useWebSocket() {
       clearTimeout(reconnectTimeoutRef.current)
     }
     if (wsRef.current) {
-      wsRef.current.close()
+      const ws = wsRef.current
       wsRef.current = null
+      ws.close()
     }
   }, [])
 
@@ -26,11 +26,45 @@ api.interceptors.request.use(
   }
 )
 
+// Refresh the access token once for all requests failing at the same time
+let refreshPromise: Promise<string> | null = null
+
+export const refreshAccessToken = () => {
+  if (!refreshPromise) {
+    const refreshToken = useAuthStore.getState().refreshToken
+    refreshPromise = axios
+      .post(`${API_URL}/api/v1/auth/refresh`, { refresh_token: refreshToken })
+      .then((response) => {
+        useAuthStore.getState().setTokens(response.data.token, response.data.refresh_token)
+        return response.data.token as string
+      })
+      .finally(() => {
+        refreshPromise = null
+      })
+  }
+  return refreshPromise
+}
+
 // Response interceptor to handle errors
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
+  async (error) => {
+    const request = error.config
+    const isAuthCall = ['/auth/login', '/auth/refresh', '/auth/2fa/', '/auth/sso/'].some((path) => request?.url?.startsWith(path))
+
+    if (error.response?.status === 401 && !isAuthCall) {
+      // Access tokens are short-lived: try a refresh before signing out
+      if (!request._retried && useAuthStore.getState().refreshToken) {
+        request._retried = true
+        try {
+          const token = await refreshAccessToken()
+          request.headers.Authorization = `Bearer ${token}`
+          return api(request)
+        } catch {
+          // fall through to sign out
+        }
+      }
+
       useAuthStore.getState().logout()
       window.location.href = '/login'
     }
@@ -50,6 +84,30 @@ export const authApi = {
     return response.data
   },
 
+  verifyTwoFactor: async (challengeToken: string, code: string) => {
+    // Recovery codes look like xxxxx-xxxxx, authenticator codes are 6 digits
+    const body = /^\d{6}$/.test(code.trim())
+      ? { challenge_token: challengeToken, code: code.trim() }
+      : { challenge_token: challengeToken, recovery_code: code.trim() }
+    const response = await api.post('/auth/2fa/verify', body)
+    return response.data
+  },
+
+  // Single sign-on: start returns the identity provider URL, callback completes the sign-in
+  startSSO: async (params: { account_id?: string; email?: string }) => {
+    const response = await api.post('/auth/sso/start', params)
+    return response.data
+  },
+
+  ssoCallback: async (code: string, state: string) => {
+    const response = await api.post('/auth/sso/callback', { code, state })
+    return response.data
+  },
+
+  logout: async () => {
+    await api.post('/auth/logout')
+  },
+
   getProfile: async () => {
     const response = await api.get('/profile')
     return response.data
@@ -68,9 +126,19 @@ export const authApi = {
     return response.data
   },
 
-  // Access Token
-  resetAccessToken: async () => {
-    const response = await api.post('/profile/access_token')
+  // API Tokens
+  listAccessTokens: async () => {
+    const response = await api.get('/profile/access_tokens')
+    return response.data
+  },
+
+  createAccessToken: async (data: { name: string; scopes: string[]; expires_in_days?: number }) => {
+    const response = await api.post('/profile/access_tokens', data)
+    return response.data
+  },
+
+  deleteAccessToken: async (id: string) => {
+    const response = await api.delete(`/profile/access_tokens/${id}`)
     return response.data
   },
 }
@@ -131,6 +199,44 @@ export const messagesApi = {
     const response = await api.post('/messages', data)
     return response.data
   },
+
+  update: async (id: string, content: string) => {
+    const response = await api.put(`/messages/${id}`, { content })
+    return response.data
+  },
+
+  delete: async (id: string) => {
+    const response = await api.delete(`/messages/${id}`)
+    return response.data
+  },
+}
+
+// Notifications API
+export const notificationsApi = {
+  list: async (params?: { page?: number; status?: 'unread' }) => {
+    const response = await api.get('/notifications', { params })
+    return response.data
+  },
+
+  markRead: async (id: string) => {
+    const response = await api.post(`/notifications/${id}/read`)
+    return response.data
+  },
+
+  markAllRead: async () => {
+    const response = await api.post('/notifications/read_all')
+    return response.data
+  },
+
+  getSettings: async () => {
+    const response = await api.get('/profile/notification_settings')
+    return response.data
+  },
+
+  updateSettings: async (data: { in_app?: Record<string, boolean>; email?: Record<string, boolean> }) => {
+    const response = await api.put('/profile/notification_settings', data)
+    return response.data
+  },
 }
 
 // Contacts API
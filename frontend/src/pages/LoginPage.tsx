@@ -1,23 +1,55 @@
 import { useState } from 'react'
-import { useNavigate, Link } from 'react-router-dom'
+import { useNavigate, useLocation, Link } from 'react-router-dom'
 import { useMutation } from '@tanstack/react-query'
 import { authApi } from '../lib/api'
 import { useAuthStore } from '../stores/authStore'
-import { LogIn, Mail, Lock, Loader2 } from 'lucide-react'
+import { LogIn, Mail, Lock, Loader2, ShieldCheck, KeyRound } from 'lucide-react'
 
 export default function LoginPage() {
   const navigate = useNavigate()
+  const location = useLocation()
   const { login } = useAuthStore()
   const [email, setEmail] = useState('')
   const [password, setPassword] = useState('')
+  // Single sign-on lands here with a challenge when the user has two-factor authentication
+  const [challengeToken, setChallengeToken] = useState<string | null>(
+    (location.state as { challengeToken?: string } | null)?.challengeToken ?? null
+  )
+  const [code, setCode] = useState('')
+
+  const ssoMutation = useMutation({
+    mutationFn: (params: { account_id?: string; email?: string }) => authApi.startSSO(params),
+    onSuccess: (data) => {
+      window.location.href = data.authorization_url
+    },
+    onError: (error: any) => {
+      alert(error.response?.data?.error || 'Single sign-on is not available')
+    },
+  })
 
   const loginMutation = useMutation({
-    mutationFn: () => authApi.login(email, password),
+    mutationFn: () =>
+      challengeToken ? authApi.verifyTwoFactor(challengeToken, code) : authApi.login(email, password),
     onSuccess: (data) => {
-      login(data.token, data.user)
+      // Accounts with two-factor authentication answer with a challenge first
+      if (data.two_factor_required) {
+        setChallengeToken(data.challenge_token)
+        return
+      }
+      login(data.token, data.user, data.refresh_token)
       navigate('/dashboard')
     },
     onError: (error: any) => {
+      // Accounts that require single sign-on send the user to their identity provider
+      if (error.response?.data?.code === 'sso_required') {
+        ssoMutation.mutate({ account_id: error.response.data.account_id })
+        return
+      }
+      // An expired challenge means starting over with the password
+      if (challengeToken && error.response?.status === 401 && error.response?.data?.error?.includes('challenge')) {
+        setChallengeToken(null)
+        setCode('')
+      }
       alert(error.response?.data?.error || 'Login failed')
     },
   })
@@ -44,43 +76,69 @@ export default function LoginPage() {
           <h2 className="text-2xl font-bold text-gray-900 mb-6">Welcome Back</h2>
 
           <form onSubmit={handleSubmit} className="space-y-6">
-            {/* Email */}
-            <div>
-              <label htmlFor="email" className="block text-sm font-medium text-gray-700 mb-2">
-                Email Address
-              </label>
-              <div className="relative">
-                <Mail className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-gray-400" />
-                <input
-                  id="email"
-                  type="email"
-                  value={email}
-                  onChange={(e) => setEmail(e.target.value)}
-                  className="input pl-10"
-                  placeholder="you@example.com"
-                  required
-                />
+            {challengeToken ? (
+              /* Two-factor code */
+              <div>
+                <label htmlFor="code" className="block text-sm font-medium text-gray-700 mb-2">
+                  Authentication code
+                </label>
+                <div className="relative">
+                  <ShieldCheck className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-gray-400" />
+                  <input
+                    id="code"
+                    type="text"
+                    inputMode="numeric"
+                    autoComplete="one-time-code"
+                    value={code}
+                    onChange={(e) => setCode(e.target.value)}
+                    className="input pl-10"
+                    placeholder="123456 or recovery code"
+                    autoFocus
+                    required
+                  />
+                </div>
               </div>
-            </div>
+            ) : (
+              <>
+                {/* Email */}
+                <div>
+                  <label htmlFor="email" className="block text-sm font-medium text-gray-700 mb-2">
+                    Email Address
+                  </label>
+                  <div className="relative">
+                    <Mail className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-gray-400" />
+                    <input
+                      id="email"
+                      type="email"
+                      value={email}
+                      onChange={(e) => setEmail(e.target.value)}
+                      className="input pl-10"
+                      placeholder="you@example.com"
+                      required
+                    />
+                  </div>
+                </div>
 
-            {/* Password */}
-            <div>
-              <label htmlFor="password" className="block text-sm font-medium text-gray-700 mb-2">
-                Password
-              </label>
-              <div className="relative">
-                <Lock className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-gray-400" />
-                <input
-                  id="password"
-                  type="password"
-                  value={password}
-                  onChange={(e) => setPassword(e.target.value)}
-                  className="input pl-10"
-                  placeholder="••••••••"
-                  required
-                />
-              </div>
-            </div>
+                {/* Password */}
+                <div>
+                  <label htmlFor="password" className="block text-sm font-medium text-gray-700 mb-2">
+                    Password
+                  </label>
+                  <div className="relative">
+                    <Lock className="absolute left-3 top-1/2 -translate-y-1/2 w-5 h-5 text-gray-400" />
+                    <input
+                      id="password"
+                      type="password"
+                      value={password}
+                      onChange={(e) => setPassword(e.target.value)}
+                      className="input pl-10"
+                      placeholder="••••••••"
+                      required
+                    />
+                  </div>
+                </div>
+              </>
+            )}
 
             {/* Submit Button */}
             <button
@@ -102,6 +160,19 @@ export default function LoginPage() {
             </button>
           </form>
 
+          {/* Single sign-on, found by the email domain */}
+          {!challengeToken && (
+            <button
+              type="button"
+              onClick={() => (email ? ssoMutation.mutate({ email }) : alert('Enter your email address first'))}
+              disabled={ssoMutation.isPending}
+              className="btn w-full mt-3 flex items-center justify-center gap-2 border border-gray-300 text-gray-700 hover:bg-gray-50"
+            >
+              {ssoMutation.isPending ? <Loader2 className="w-5 h-5 animate-spin" /> : <KeyRound className="w-5 h-5" />}
+              Sign in with SSO
+            </button>
+          )}
+
           {/* Register Link */}
           <div className="mt-6 text-center">
             <p className="text-sm text-gray-600">
@@ -15,7 +15,7 @@ export default function RegisterPage() {
   const registerMutation = useMutation({
     mutationFn: () => authApi.register(name, email, password),
     onSuccess: (data) => {
-      login(data.token, data.user)
+      login(data.token, data.user, data.refresh_token)
       navigate('/dashboard')
     },
     onError: (error: any) => {
@@ -0,0 +1,60 @@
+import { useEffect, useRef, useState } from 'react'
+import { useNavigate, useSearchParams, Link } from 'react-router-dom'
+import { Loader2 } from 'lucide-react'
+import { authApi } from '../lib/api'
+import { useAuthStore } from '../stores/authStore'
+
+// The identity provider redirects here with a code and state, which the backend
+// exchanges for a session
+export default function SSOCallbackPage() {
+  const navigate = useNavigate()
+  const [params] = useSearchParams()
+  const { login } = useAuthStore()
+  const [error, setError] = useState<string | null>(null)
+  const started = useRef(false)
+
+  useEffect(() => {
+    // Codes are single use: never post twice (React strict mode runs effects twice)
+    if (started.current) return
+    started.current = true
+
+    const code = params.get('code')
+    const state = params.get('state')
+    if (!code || !state) {
+      setError(params.get('error_description') || params.get('error') || 'Missing sign-in response')
+      return
+    }
+
+    authApi
+      .ssoCallback(code, state)
+      .then((data) => {
+        if (data.two_factor_required) {
+          navigate('/login', { replace: true, state: { challengeToken: data.challenge_token } })
+          return
+        }
+        login(data.token, data.user, data.refresh_token)
+        navigate('/dashboard', { replace: true })
+      })
+      .catch((err: any) => setError(err.response?.data?.error || 'Single sign-on failed'))
+  }, [])
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-primary-600 via-primary-700 to-primary-900 p-4">
+      <div className="w-full max-w-md bg-white rounded-2xl shadow-2xl p-8 text-center">
+        {error ? (
+          <>
+            <p className="text-gray-900 font-medium mb-4">{error}</p>
+            <Link to="/login" className="text-primary-600 hover:text-primary-700 font-medium">
+              Back to sign in
+            </Link>
+          </>
+        ) : (
+          <div className="flex items-center justify-center gap-2 text-gray-700">
+            <Loader2 className="w-5 h-5 animate-spin" />
+            Signing in...
+          </div>
+        )}
+      </div>
+    </div>
+  )
+}
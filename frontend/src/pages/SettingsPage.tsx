@@ -2,7 +2,7 @@ import React, { useState, useEffect, useRef } from 'react'
 import { 
   User, Mail, Lock, Key, Keyboard, 
   Camera, Save, RefreshCw, Copy, Check,
-  Shield, AlertCircle
+  Shield, AlertCircle, Plus, Trash2
 } from 'lucide-react'
 import { useAuthStore } from '../stores/authStore'
 import { authApi, storageApi } from '../lib/api'
@@ -17,7 +17,17 @@ export default function SettingsPage() {
     avatar: null as string | null,
     sendShortcut: 'enter',
   })
-  // Token state removed, using user.access_token directly
+
+  // API tokens
+  const tokenScopes = [
+    'conversations:read', 'conversations:write',
+    'messages:read', 'messages:write',
+    'contacts:read', 'contacts:write',
+    'inboxes:read', 'webhooks:ingest',
+  ]
+  const [accessTokens, setAccessTokens] = useState<any[]>([])
+  const [newToken, setNewToken] = useState({ name: '', scopes: [] as string[], expiresInDays: '' })
+  const [createdToken, setCreatedToken] = useState<string | null>(null)
 
   const [localAccounts, setLocalAccounts] = useState<any[]>([])
 
@@ -38,8 +48,17 @@ export default function SettingsPage() {
         }
     }
     refreshProfile()
+    loadAccessTokens()
   }, [])
 
+  const loadAccessTokens = async () => {
+    try {
+      setAccessTokens(await authApi.listAccessTokens())
+    } catch (error) {
+      console.error('Failed to load API tokens:', error)
+    }
+  }
+
   useEffect(() => {
     if (user) {
       setUserData(prev => ({
@@ -179,7 +198,11 @@ export default function SettingsPage() {
     
     setIsSaving(true)
     try {
-      await authApi.changePassword(passwords.current, passwords.new)
+      const response = await authApi.changePassword(passwords.current, passwords.new)
+      // Changing the password signs out every session; continue on the new one
+      if (response.token) {
+        useAuthStore.getState().setTokens(response.token, response.refresh_token)
+      }
       alert('Senha alterada com sucesso!')
       setPasswords({ current: '', new: '', confirm: '' })
     } catch (error: any) {
@@ -192,11 +215,46 @@ export default function SettingsPage() {
   }
 
   const copyToken = () => {
-    navigator.clipboard.writeText(user?.access_token || '')
+    navigator.clipboard.writeText(createdToken || '')
     setCopiedToken(true)
     setTimeout(() => setCopiedToken(false), 2000)
   }
 
+  const toggleTokenScope = (scope: string) => {
+    setNewToken(prev => ({
+      ...prev,
+      scopes: prev.scopes.includes(scope)
+        ? prev.scopes.filter(s => s !== scope)
+        : [...prev.scopes, scope]
+    }))
+  }
+
+  const handleCreateToken = async () => {
+    try {
+      const token = await authApi.createAccessToken({
+        name: newToken.name,
+        scopes: newToken.scopes,
+        expires_in_days: newToken.expiresInDays ? Number(newToken.expiresInDays) : undefined,
+      })
+      // The token is only returned once
+      setCreatedToken(token.token)
+      setNewToken({ name: '', scopes: [], expiresInDays: '' })
+      loadAccessTokens()
+    } catch (error: any) {
+      alert(error.response?.data?.error || 'Erro ao criar token')
+    }
+  }
+
+  const handleDeleteToken = async (id: string) => {
+    if (!confirm('Revogar este token? Integrações que o utilizam deixarão de funcionar.')) return
+    try {
+      await authApi.deleteAccessToken(id)
+      setAccessTokens(prev => prev.filter(t => t.id !== id))
+    } catch (error: any) {
+      alert(error.response?.data?.error || 'Erro ao revogar token')
+    }
+  }
+
   if (!user) return <div className="p-8 text-white">Carregando...</div>
 
   return (
@@ -394,7 +452,7 @@ export default function SettingsPage() {
           <div className="p-6 border-b border-gray-700 flex items-center justify-between">
             <div className="flex items-center gap-3">
               <Key className="w-5 h-5 text-yellow-500" />
-              <h2 className="text-lg font-semibold text-white">Token de Acesso</h2>
+              <h2 className="text-lg font-semibold text-white">Tokens de API</h2>
             </div>
             <button 
               onClick={handleCopyAccountId}
@@ -413,26 +471,104 @@ export default function SettingsPage() {
             <div className="bg-blue-900/20 border border-blue-800 p-4 rounded-lg flex gap-3">
               <AlertCircle className="w-5 h-5 text-blue-400 flex-shrink-0" />
               <p className="text-sm text-blue-200">
-                Este token pode ser usado para integrar outras aplicações via API. Mantenha-o seguro!
+                Tokens permitem integrar outras aplicações via API, limitados aos escopos escolhidos e à conta ativa. Mantenha-os seguros!
               </p>
             </div>
 
-            <div className="space-y-2">
-              <label className="text-sm font-medium text-gray-300">Seu Token de Acesso</label>
+            {createdToken && (
+              <div className="space-y-2">
+                <label className="text-sm font-medium text-gray-300">Novo token</label>
+                <div className="flex gap-2">
+                  <code className="flex-1 bg-gray-900 border border-gray-700 rounded-lg px-4 py-3 text-gray-300 font-mono text-sm break-all">
+                    {createdToken}
+                  </code>
+                  <button
+                    onClick={copyToken}
+                    className="flex-shrink-0 px-4 py-2 bg-gray-700 hover:bg-gray-600 text-white rounded-lg transition-colors flex items-center gap-2"
+                  >
+                    {copiedToken ? <Check className="w-4 h-4" /> : <Copy className="w-4 h-4" />}
+                    {copiedToken ? 'Copiado!' : 'Copiar'}
+                  </button>
+                </div>
+                <p className="text-xs text-yellow-500 mt-1">
+                  Copie o token agora, ele não será exibido novamente.
+                </p>
+              </div>
+            )}
+
+            <div className="space-y-3">
+              <label className="text-sm font-medium text-gray-300">Criar token</label>
               <div className="flex gap-2">
-                <code className="flex-1 bg-gray-900 border border-gray-700 rounded-lg px-4 py-3 text-gray-300 font-mono text-sm break-all">
-                  {user?.access_token || 'Token não disponível'}
-                </code>
+                <input
+                  type="text"
+                  value={newToken.name}
+                  onChange={(e) => setNewToken({ ...newToken, name: e.target.value })}
+                  placeholder="Nome (ex: Integração WhatsApp)"
+                  className="flex-1 bg-gray-900 border border-gray-700 rounded-lg px-4 py-2 text-white focus:outline-none focus:border-primary-500"
+                />
+                <input
+                  type="number"
+                  min={1}
+                  value={newToken.expiresInDays}
+                  onChange={(e) => setNewToken({ ...newToken, expiresInDays: e.target.value })}
+                  placeholder="Expira em (dias)"
+                  className="w-40 bg-gray-900 border border-gray-700 rounded-lg px-4 py-2 text-white focus:outline-none focus:border-primary-500"
+                />
+              </div>
+              <div className="flex flex-wrap gap-2">
+                {tokenScopes.map(scope => (
+                  <label key={scope} className="flex items-center gap-1 text-xs text-gray-300 bg-gray-900 border border-gray-700 rounded px-2 py-1 cursor-pointer">
+                    <input
+                      type="checkbox"
+                      checked={newToken.scopes.includes(scope)}
+                      onChange={() => toggleTokenScope(scope)}
+                    />
+                    <span className="font-mono">{scope}</span>
+                  </label>
+                ))}
+              </div>
+              <div className="flex justify-end">
                 <button
-                  onClick={copyToken}
-                  className="flex-shrink-0 px-4 py-2 bg-gray-700 hover:bg-gray-600 text-white rounded-lg transition-colors flex items-center gap-2"
+                  onClick={handleCreateToken}
+                  disabled={!newToken.name || newToken.scopes.length === 0}
+                  className="px-4 py-2 bg-gray-700 hover:bg-gray-600 text-white rounded-lg font-medium transition-colors flex items-center gap-2 disabled:opacity-50 disabled:cursor-not-allowed"
                 >
-                  {copiedToken ? <Check className="w-4 h-4" /> : <Copy className="w-4 h-4" />}
-                  {copiedToken ? 'Copiado!' : 'Copiar'}
+                  <Plus className="w-4 h-4" />
+                  Criar Token
                 </button>
               </div>
+            </div>
+
+            <div className="space-y-2">
+              <label className="text-sm font-medium text-gray-300">Seus tokens</label>
+              {accessTokens.length === 0 ? (
+                <p className="text-sm text-gray-500">Nenhum token criado.</p>
+              ) : (
+                accessTokens.map(token => (
+                  <div key={token.id} className="flex items-center justify-between bg-gray-900 border border-gray-700 rounded-lg px-4 py-3">
+                    <div className="min-w-0">
+                      <p className="text-sm text-white">
+                        {token.name} <span className="font-mono text-gray-500">{token.prefix}…</span>
+                      </p>
+                      <p className="text-xs text-gray-500 truncate">{token.scopes}</p>
+                      <p className="text-xs text-gray-500">
+                        {token.last_used_at ? `Último uso: ${new Date(token.last_used_at).toLocaleString()}` : 'Nunca usado'}
+                        {' · '}
+                        {token.expires_at ? `Expira em ${new Date(token.expires_at).toLocaleDateString()}` : 'Sem expiração'}
+                      </p>
+                    </div>
+                    <button
+                      onClick={() => handleDeleteToken(token.id)}
+                      className="flex-shrink-0 p-2 text-gray-400 hover:text-red-400 transition-colors"
+                      title="Revogar token"
+                    >
+                      <Trash2 className="w-4 h-4" />
+                    </button>
+                  </div>
+                ))
+              )}
               <p className="text-xs text-gray-500 mt-1">
-                Token para autenticação Bearer em requisições API.
+                Envie o token como <code>Authorization: Bearer</code> ou no header <code>api_access_token</code>.
               </p>
             </div>
           </div>
@@ -9,19 +9,21 @@ export interface User {
   avatar?: string
   role: string
   availability: string
+  auto_offline?: boolean
   ui_settings?: {
     send_shortcut?: 'enter' | 'ctrl_enter'
     [key: string]: any
   }
-  access_token?: string
   accounts?: { id: string; name: string }[]
 }
 
 interface AuthState {
   user: User | null
   token: string | null
+  refreshToken: string | null
   isAuthenticated: boolean
-  login: (token: string, user: User) => void
+  login: (token: string, user: User, refreshToken?: string) => void
+  setTokens: (token: string, refreshToken?: string) => void
   logout: () => void
   updateUser: (user: Partial<User>) => void
 }
@@ -31,14 +33,24 @@ export const useAuthStore = create<AuthState>()(
     (set) => ({
       user: null,
       token: null,
+      refreshToken: null,
       isAuthenticated: false,
 
-      login: (token, user) => {
-        set({ token, user, isAuthenticated: true })
+      login: (token, user, refreshToken) => {
+        set((state) => ({
+          token,
+          user,
+          refreshToken: refreshToken ?? state.refreshToken,
+          isAuthenticated: true,
+        }))
+      },
+
+      setTokens: (token, refreshToken) => {
+        set((state) => ({ token, refreshToken: refreshToken ?? state.refreshToken }))
       },
 
       logout: () => {
-        set({ token: null, user: null, isAuthenticated: false })
+        set({ token: null, refreshToken: null, user: null, isAuthenticated: false })
       },
 
       updateUser: (userData) => {
@@ -6,6 +6,7 @@ interface Notification {
   title: string
   body: string
   conversationId?: string
+  serverId?: string // ID of the stored notification, for those kept by the server
   read: boolean
   createdAt: Date
 }